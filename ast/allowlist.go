@@ -0,0 +1,145 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	keywordOneOf = "[oneof]"
+	keywordDir   = "[dir]"
+)
+
+// directions are the values allowed in a [dir] block.
+var directions = []string{"ASC", "DESC"}
+
+// AllowlistBlock represents a parsed syntax state of a [oneof] or [dir] block,
+// which evaluates to the value of the variable, as long as it's one of the
+// allowed values.
+type AllowlistBlock struct {
+	// Keyword is either [oneof] or [dir].
+	Keyword string
+	Name    string
+	// Values are the allowed values declared in the template. If there are
+	// none, the ones given in the Allowlists of the Env are used.
+	Values []string
+}
+
+// Evaluate returns the value of the variable, or an error if it's not one of
+// the allowed values. The value of a [dir] block is matched case
+// insensitively, and is always upper case; it can be empty as well, which
+// leaves the default direction.
+func (ab *AllowlistBlock) Evaluate(env *Env) (string, error) {
+	if ab == nil {
+		return "", nil
+	}
+	v, ok, err := env.lookup(ab.Name, "")
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return ab.Name, nil
+	}
+	if v, err = driverValue(v); err != nil {
+		return "", err
+	}
+	if v == nil {
+		v = ""
+	}
+	s, isString := v.(string)
+	if !isString {
+		return "", errors.Errorf("%s must be a string, got %T", ab.Name, v)
+	}
+
+	if ab.Keyword == keywordDir {
+		if s == "" {
+			return "", nil
+		}
+		for _, d := range directions {
+			if strings.EqualFold(s, d) {
+				return d, nil
+			}
+		}
+		return "", errors.Errorf("%s must be ASC or DESC, got %q", ab.Name, s)
+	}
+
+	values := ab.Values
+	if len(values) == 0 && env != nil {
+		values = env.Allowlists[ab.Name]
+	}
+	if len(values) == 0 {
+		return "", errors.Errorf("no allowed values declared for %s", ab.Name)
+	}
+	for _, allowed := range values {
+		if s == allowed {
+			return s, nil
+		}
+	}
+	return "", errors.Errorf("%s must be one of %s, got %q", ab.Name, strings.Join(values, ", "), s)
+}
+
+// Variables returns the variable of this AllowlistBlock.
+func (ab *AllowlistBlock) Variables() []Variable {
+	if ab == nil {
+		return nil
+	}
+	return []Variable{{Name: ab.Name, Value: true}}
+}
+
+// isAllowlistBlock checks if the TokenTree is analyzed to a [oneof] or [dir]
+// block.
+func isAllowlistBlock(tt *TokenTree) (bool, error) {
+	if len(tt.chunks) == 0 {
+		return false, errors.New("expression with empty chunks")
+	}
+
+	keyword, ok := tt.chunks[0].(*Literal)
+	if !ok || (keyword.String() != keywordOneOf && keyword.String() != keywordDir) {
+		return false, nil
+	}
+
+	if len(tt.chunks) < 2 {
+		return false, errors.Errorf("%s must be followed by a variable", keyword)
+	}
+	if name, ok := tt.chunks[1].(*Literal); !ok || !isVariable(name.String()) {
+		return false, errors.Errorf("%s must be followed by a variable", keyword)
+	}
+	if keyword.String() == keywordDir && len(tt.chunks) > 2 {
+		return false, errors.New("[dir] must be followed by a single variable")
+	}
+	for _, chunk := range tt.chunks[2:] {
+		if l, ok := chunk.(*Literal); !ok || IsKeyword(l.String()) {
+			return false, errors.New("allowed values must be quoted strings")
+		}
+	}
+
+	return true, nil
+}
+
+// parseAllowlistBlock parses the TokenTree and returns the parsed
+// AllowlistBlock. It assumes the TokenTree is a valid [oneof] or [dir] block
+// (make sure to call isAllowlistBlock first).
+func parseAllowlistBlock(tt *TokenTree) (*AllowlistBlock, error) {
+	ab := &AllowlistBlock{
+		Keyword: tt.chunks[0].(*Literal).String(),
+		Name:    tt.chunks[1].(*Literal).String(),
+	}
+
+	words := make([]string, len(tt.chunks)-2)
+	for i, chunk := range tt.chunks[2:] {
+		words[i] = chunk.(*Literal).String()
+	}
+	operands, err := parseOperands(words)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range operands {
+		s, ok := o.Value.(string)
+		if !ok || o.Variable != "" {
+			return nil, errors.New("allowed values must be quoted strings")
+		}
+		ab.Values = append(ab.Values, s)
+	}
+	return ab, nil
+}
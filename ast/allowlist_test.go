@@ -0,0 +1,122 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestSyntaxTree_EvaluateAllowlist(t *testing.T) {
+	cases := []struct {
+		desc         string
+		input        string
+		inputEnv     *Env
+		expected     string
+		isParseError bool
+		isError      bool
+	}{
+		{
+			desc:     "Allowed value",
+			input:    `ORDER BY {{ [oneof] .Sort "price" "created_at" }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": "created_at"}},
+			expected: "ORDER BY created_at",
+		},
+		{
+			desc:     "Value with spaces",
+			input:    `ORDER BY {{ [oneof] .Sort "price" "lower(name) ASC" }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": "lower(name) ASC"}},
+			expected: "ORDER BY lower(name) ASC",
+		},
+		{
+			desc:     "Value not allowed",
+			input:    `ORDER BY {{ [oneof] .Sort "price" "created_at" }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": "price; DROP TABLE products"}},
+			isError:  true,
+		},
+		{
+			desc:     "Not a string",
+			input:    `ORDER BY {{ [oneof] .Sort "1" }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": 1}},
+			isError:  true,
+		},
+		{
+			desc:  "Allowlist of the Env",
+			input: `ORDER BY {{ [oneof] .Sort }}`,
+			inputEnv: &Env{
+				Vars:       map[string]interface{}{".Sort": "name"},
+				Allowlists: map[string][]string{".Sort": {"price", "name"}},
+			},
+			expected: "ORDER BY name",
+		},
+		{
+			desc:     "No allowlist",
+			input:    `ORDER BY {{ [oneof] .Sort }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": "name"}},
+			isError:  true,
+		},
+		{
+			desc:     "Direction",
+			input:    `ORDER BY price {{ [dir] .Order }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Order": "desc"}},
+			expected: "ORDER BY price DESC",
+		},
+		{
+			desc:     "Empty direction",
+			input:    `ORDER BY price {{ [dir] .Order }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Order": ""}},
+			expected: "ORDER BY price",
+		},
+		{
+			desc:     "Invalid direction",
+			input:    `ORDER BY price {{ [dir] .Order }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Order": "DESC, 1"}},
+			isError:  true,
+		},
+		{
+			desc:     "Missing variable is left as-is",
+			input:    `ORDER BY price {{ [dir] .Order }}`,
+			inputEnv: &Env{},
+			expected: "ORDER BY price .Order",
+		},
+		{
+			desc:         "Unquoted value",
+			input:        `ORDER BY {{ [oneof] .Sort price }}`,
+			isParseError: true,
+		},
+		{
+			desc:         "Direction with values",
+			input:        `ORDER BY {{ [dir] .Order "ASC" }}`,
+			isParseError: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			st, err := tt.Parse()
+			if c.isParseError {
+				if err == nil {
+					t.Error("Expected parse error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := st.Evaluate(c.inputEnv)
+			if c.isError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if result != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package ast
+
+// Block describes an [if] block of a template. Its clauses are copies, so
+// modifying them doesn't affect the template.
+type Block struct {
+	// Predicate is the predicate as written in the template, such as
+	// .IncludeReviews or .Role | isAdmin, without its default value.
+	Predicate string
+	// Default is the default value of the predicate, or empty if there's none.
+	Default string
+	// Variables are the variables referenced in the predicate.
+	Variables []Variable
+	// Then is the [then] clause.
+	Then *SyntaxTree
+	// Else is the [else] clause, or nil if there's none.
+	Else *SyntaxTree
+	// Depth is the number of [if] blocks the block is nested in.
+	Depth int
+}
+
+// Children returns a copy of the nodes of the tree, which can be modified
+// without affecting the tree.
+func (t *SyntaxTree) Children() []LanguageNode {
+	if t == nil {
+		return nil
+	}
+	return append([]LanguageNode(nil), t.Nodes...)
+}
+
+// Blocks returns the [if] blocks of the tree depth-first, in the order they
+// appear. The blocks of a partial are listed where it's included, rather than
+// where it's defined.
+func (t *SyntaxTree) Blocks() []Block {
+	var blocks []Block
+	collectBlocks(t, 0, &blocks)
+	return blocks
+}
+
+func collectBlocks(n LanguageNode, depth int, blocks *[]Block) {
+	switch n := n.(type) {
+	case *SyntaxTree:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectBlocks(child, depth, blocks)
+		}
+	case *IfBlock:
+		*blocks = append(*blocks, Block{
+			Predicate: n.predicateString(),
+			Default:   n.PredicateDefault,
+			Variables: n.predicateVariables(),
+			Then:      copyTree(n.Then),
+			Else:      copyTree(n.Else),
+			Depth:     depth,
+		})
+		collectBlocks(n.Then, depth+1, blocks)
+		collectBlocks(n.Else, depth+1, blocks)
+	case *IncludeBlock:
+		collectBlocks(n.Tree, depth, blocks)
+	}
+}
+
+// copyTree returns a deep copy of the tree, including the partials it
+// includes, or nil if t is nil.
+func copyTree(t *SyntaxTree) *SyntaxTree {
+	if t == nil {
+		return nil
+	}
+	// Rewrite copies the SyntaxTrees and the blocks with clauses, and copyNode
+	// the other nodes.
+	return Rewrite(t, copyNode).(*SyntaxTree)
+}
+
+func copyNode(node LanguageNode) LanguageNode {
+	switch n := node.(type) {
+	case *Literal:
+		c := *n
+		return &c
+	case *IfBlock:
+		// The clauses are copied already.
+		n.PredicateExpr = append([]string(nil), n.PredicateExpr...)
+		n.PredicatePipe = copyPipeline(n.PredicatePipe)
+		return n
+	case *Pipeline:
+		return copyPipeline(n)
+	case *IdentBlock:
+		c := *n
+		return &c
+	case *RawBlock:
+		c := *n
+		return &c
+	case *AllowlistBlock:
+		c := *n
+		c.Values = append([]string(nil), n.Values...)
+		return &c
+	case *DefaultBlock:
+		c := *n
+		c.Value = append([]string(nil), n.Value...)
+		return &c
+	case *ParamsBlock:
+		c := *n
+		c.Params = append([]Param(nil), n.Params...)
+		return &c
+	case *IncludeBlock:
+		c := *n
+		c.Tree = copyTree(n.Tree)
+		return &c
+	}
+	return node
+}
+
+func copyPipeline(p *Pipeline) *Pipeline {
+	if p == nil {
+		return nil
+	}
+	c := &Pipeline{Operand: p.Operand, Cmds: make([]Command, len(p.Cmds))}
+	for i, cmd := range p.Cmds {
+		c.Cmds[i] = Command{Name: cmd.Name, Args: append([]Operand(nil), cmd.Args...)}
+	}
+	return c
+}
@@ -0,0 +1,123 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestSyntaxTree_Blocks(t *testing.T) {
+	input := `{{ [define] stock {{ [if] .InStock [default] true [then] AND stock > 0 }} }}
+		SELECT * FROM products WHERE deleted_at IS NULL {{ [include] stock }}
+		{{ [if] .Role | hasRole "admin" [then]
+			{{ [if] .IncludeReviews [then] AND reviewed }}
+		[else] AND public }}`
+	tt, err := BuildTokenTree(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	node, err := tt.Parse()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	st := node.(*SyntaxTree)
+	defines, err := st.Defines()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = st.ResolveIncludes(defines); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	type block struct {
+		Predicate string
+		Default   string
+		Variables []Variable
+		HasElse   bool
+		Depth     int
+	}
+	var blocks []block
+	for _, b := range st.Blocks() {
+		blocks = append(blocks, block{
+			Predicate: b.Predicate,
+			Default:   b.Default,
+			Variables: b.Variables,
+			HasElse:   b.Else != nil,
+			Depth:     b.Depth,
+		})
+	}
+	expected := []block{
+		{
+			Predicate: ".InStock",
+			Default:   "true",
+			Variables: []Variable{{Name: ".InStock", Predicate: true, HasDefault: true}},
+		},
+		{
+			Predicate: `.Role | hasRole "admin"`,
+			Variables: []Variable{{Name: ".Role", Predicate: true, Piped: true}},
+			HasElse:   true,
+		},
+		{
+			Predicate: ".IncludeReviews",
+			Variables: []Variable{{Name: ".IncludeReviews", Predicate: true}},
+			Depth:     1,
+		},
+	}
+	if diff := deep.Equal(expected, blocks); diff != nil {
+		t.Errorf("Wrong result: %v", diff)
+	}
+}
+
+func TestSyntaxTree_BlocksCopy(t *testing.T) {
+	input := `{{ [define] stock AND stock > 0 }}
+		SELECT * FROM products {{ [if] .InStock [then] WHERE true {{ [include] stock }} }}`
+	tt, err := BuildTokenTree(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	node, err := tt.Parse()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	st := node.(*SyntaxTree)
+	defines, err := st.Defines()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err = st.ResolveIncludes(defines); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	env := &Env{Vars: map[string]interface{}{".InStock": true}}
+	before, err := st.Evaluate(env)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	then := st.Blocks()[0].Then
+	walk(then, func(n LanguageNode) {
+		switch n := n.(type) {
+		case *Literal:
+			n.Text = "DELETE"
+		case *IncludeBlock:
+			n.Tree.Nodes = nil
+		}
+	})
+	then.Nodes = append(then.Nodes, &Literal{"LIMIT 1"})
+
+	after, err := st.Evaluate(env)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if after != before {
+		t.Errorf("Expected %q, got %q", before, after)
+	}
+}
+
+func TestSyntaxTree_Children(t *testing.T) {
+	st := &SyntaxTree{Nodes: []LanguageNode{&Literal{"SELECT"}, &Literal{".Column"}}}
+	children := st.Children()
+	children[0] = &Literal{"DELETE"}
+	if s := st.Nodes[0].(*Literal).String(); s != "SELECT" {
+		t.Errorf("Expected the tree to be unchanged, got %s", s)
+	}
+}
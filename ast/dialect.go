@@ -0,0 +1,209 @@
+package ast
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Dialect is the SQL dialect of the query, which determines the syntax of
+// bind placeholders, quoted identifiers and literals.
+type Dialect int
+
+const (
+	// Postgres uses $1 placeholders and "double quoted" identifiers.
+	Postgres Dialect = iota
+	// MySQL uses ? placeholders and `backquoted` identifiers.
+	MySQL
+	// SQLite uses ? placeholders and "double quoted" identifiers.
+	SQLite
+	// SQLServer uses @p1 placeholders and [bracketed] identifiers.
+	SQLServer
+)
+
+// String returns the name of the dialect.
+func (d Dialect) String() string {
+	switch d {
+	case Postgres:
+		return "postgres"
+	case MySQL:
+		return "mysql"
+	case SQLite:
+		return "sqlite"
+	case SQLServer:
+		return "sqlserver"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+// ParseDialect returns the dialect of the given name, as returned by String,
+// in any case.
+func ParseDialect(name string) (Dialect, error) {
+	for _, d := range []Dialect{Postgres, MySQL, SQLite, SQLServer} {
+		if strings.EqualFold(name, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, errors.Errorf("unknown dialect %q", name)
+}
+
+// Placeholder returns the placeholder of the n-th bind arg, starting at 1.
+func (d Dialect) Placeholder(n int) string {
+	switch d {
+	case MySQL, SQLite:
+		return "?"
+	case SQLServer:
+		return "@p" + strconv.Itoa(n)
+	}
+	return "$" + strconv.Itoa(n)
+}
+
+// QuoteIdent quotes the identifier, which may be qualified with a schema,
+// such as public.products. Each part must consist of letters, digits, _ and
+// $, and must not start with a digit or $.
+func (d Dialect) QuoteIdent(name string) (string, error) {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if !isIdent(part) {
+			return "", errors.Errorf("invalid identifier %q", name)
+		}
+		switch d {
+		case MySQL:
+			parts[i] = "`" + part + "`"
+		case SQLServer:
+			parts[i] = "[" + part + "]"
+		default:
+			parts[i] = `"` + part + `"`
+		}
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		isLetter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		isOther := (r >= '0' && r <= '9') || r == '$'
+		if !isLetter && !(isOther && i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// Literal returns v as a SQL literal, to be inlined in the query:
+//   - nil as NULL
+//   - strings quoted and escaped
+//   - negative numbers in parentheses, such as (-1), so they can't make a
+//     comment with a preceding minus sign
+//   - booleans as TRUE or FALSE, or as 1 or 0 in SQLite and SQLServer
+//   - time.Time as a quoted ISO 8601 timestamp
+//   - []byte as a hex literal
+//
+// Pointers are dereferenced, and a driver.Valuer such as sql.NullString is
+// replaced with its value. NaN and infinite floats have no literal, and are
+// an error.
+func (d Dialect) Literal(v interface{}) (string, error) {
+	v, err := driverValue(v)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case time.Time:
+		return d.timeLiteral(v), nil
+	case []byte:
+		if v == nil {
+			return "NULL", nil
+		}
+		return d.bytesLiteral(v), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return d.quoteString(rv.String()), nil
+	case reflect.Bool:
+		return d.boolLiteral(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return numberLiteral(strconv.FormatInt(rv.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", errors.Errorf("no literal for float %v", f)
+		}
+		return numberLiteral(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "NULL", nil
+		}
+		return d.Literal(rv.Elem().Interface())
+	}
+	return "", errors.Errorf("unsupported literal type %T", v)
+}
+
+// numberLiteral parenthesizes a negative number, which would otherwise start
+// a comment after a minus sign, as in 1--1.
+func numberLiteral(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func (d Dialect) boolLiteral(b bool) string {
+	switch {
+	case (d == SQLite || d == SQLServer) && b:
+		return "1"
+	case d == SQLite || d == SQLServer:
+		return "0"
+	case b:
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+func (d Dialect) timeLiteral(t time.Time) string {
+	if d == MySQL {
+		// MySQL doesn't accept time zones in DATETIME literals, so use UTC.
+		return "'" + t.UTC().Format("2006-01-02 15:04:05.999999") + "'"
+	}
+	return "'" + t.Format(time.RFC3339Nano) + "'"
+}
+
+func (d Dialect) bytesLiteral(b []byte) string {
+	switch d {
+	case Postgres:
+		return `'\x` + hex.EncodeToString(b) + "'"
+	case SQLServer:
+		return "0x" + hex.EncodeToString(b)
+	}
+	return "X'" + hex.EncodeToString(b) + "'"
+}
+
+// quoteString returns s as a single quoted string literal.
+func (d Dialect) quoteString(s string) string {
+	return "'" + d.EscapeString(s, '\'') + "'"
+}
+
+// EscapeString escapes s to be put inside a string literal delimited by
+// delim, by doubling the delimiter.
+func (d Dialect) EscapeString(s string, delim byte) string {
+	if d == MySQL {
+		// MySQL treats backslashes as escape characters in string literals.
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return strings.ReplaceAll(s, string(delim), string([]byte{delim, delim}))
+}
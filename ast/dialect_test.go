@@ -0,0 +1,128 @@
+package ast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDialect_QuoteIdent(t *testing.T) {
+	cases := []struct {
+		desc     string
+		dialect  Dialect
+		input    string
+		expected string
+		isError  bool
+	}{
+		{desc: "Postgres", dialect: Postgres, input: "products", expected: `"products"`},
+		{desc: "Qualified", dialect: Postgres, input: "public.products", expected: `"public"."products"`},
+		{desc: "MySQL", dialect: MySQL, input: "products", expected: "`products`"},
+		{desc: "SQLite", dialect: SQLite, input: "products", expected: `"products"`},
+		{desc: "SQLServer", dialect: SQLServer, input: "dbo.products", expected: `[dbo].[products]`},
+		{desc: "Digits and $", dialect: Postgres, input: "col_1$", expected: `"col_1$"`},
+		{desc: "Empty", dialect: Postgres, input: "", isError: true},
+		{desc: "Empty part", dialect: Postgres, input: "public.", isError: true},
+		{desc: "Leading digit", dialect: Postgres, input: "1col", isError: true},
+		{desc: "Injection", dialect: Postgres, input: `name"; DROP TABLE products; --`, isError: true},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := c.dialect.QuoteIdent(c.input)
+			if c.isError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if result != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, result)
+			}
+		})
+	}
+}
+
+func TestDialect_Literal(t *testing.T) {
+	str := "abc"
+	var nilStr *string
+	ts := time.Date(2024, 1, 2, 15, 4, 5, 0, time.FixedZone("", 9*60*60))
+	cases := []struct {
+		desc     string
+		dialect  Dialect
+		input    interface{}
+		expected string
+		isError  bool
+	}{
+		{desc: "Nil", input: nil, expected: "NULL"},
+		{desc: "String", input: "it's", expected: `'it''s'`},
+		{desc: "Backslash", input: `a\b`, expected: `'a\b'`},
+		{desc: "MySQL backslash", dialect: MySQL, input: `a\'b`, expected: `'a\\''b'`},
+		{desc: "Bool", input: true, expected: "TRUE"},
+		{desc: "Int", input: 42, expected: "42"},
+		{desc: "Negative int", input: -42, expected: "(-42)"},
+		{desc: "Uint", input: uint8(7), expected: "7"},
+		{desc: "Float", input: 1.5, expected: "1.5"},
+		{desc: "Negative float", input: float32(-1.5), expected: "(-1.5)"},
+		{desc: "NaN", input: math.NaN(), isError: true},
+		{desc: "Infinity", input: math.Inf(1), isError: true},
+		{desc: "Negative infinity", input: math.Inf(-1), isError: true},
+		{desc: "Pointer", input: &str, expected: `'abc'`},
+		{desc: "Nil pointer", input: nilStr, expected: "NULL"},
+		{desc: "SQLite bool", dialect: SQLite, input: true, expected: "1"},
+		{desc: "SQLServer bool", dialect: SQLServer, input: false, expected: "0"},
+		{desc: "Time", input: ts, expected: `'2024-01-02T15:04:05+09:00'`},
+		{desc: "MySQL time", dialect: MySQL, input: ts, expected: `'2024-01-02 06:04:05'`},
+		{desc: "Bytes", input: []byte{0xde, 0xad}, expected: `'\xdead'`},
+		{desc: "MySQL bytes", dialect: MySQL, input: []byte{0xde, 0xad}, expected: `X'dead'`},
+		{desc: "SQLServer bytes", dialect: SQLServer, input: []byte{0xde, 0xad}, expected: `0xdead`},
+		{desc: "Nil bytes", input: []byte(nil), expected: "NULL"},
+		{desc: "Unsupported", input: []int{1}, isError: true},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := c.dialect.Literal(c.input)
+			if c.isError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if result != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, result)
+			}
+		})
+	}
+}
+
+func TestDialect_Placeholder(t *testing.T) {
+	expected := map[Dialect]string{
+		Postgres:  "$2",
+		MySQL:     "?",
+		SQLite:    "?",
+		SQLServer: "@p2",
+	}
+	for d, e := range expected {
+		if result := d.Placeholder(2); result != e {
+			t.Errorf("%s: expected %s, got %s", d, e, result)
+		}
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	for _, d := range []Dialect{Postgres, MySQL, SQLite, SQLServer} {
+		if result, err := ParseDialect(d.String()); err != nil || result != d {
+			t.Errorf("%s: expected %s, got %s, %v", d, d, result, err)
+		}
+	}
+	if result, err := ParseDialect("MySQL"); err != nil || result != MySQL {
+		t.Errorf("Expected mysql, got %s, %v", result, err)
+	}
+	if _, err := ParseDialect("oracle"); err == nil {
+		t.Errorf("Expected an error for an unknown dialect")
+	}
+}
@@ -0,0 +1,137 @@
+package ast
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// MissingKey controls what happens when the template references a variable
+// which isn't defined in the Env.
+type MissingKey int
+
+const (
+	// MissingKeyLeave leaves the reference in the template as-is.
+	MissingKeyLeave MissingKey = iota
+	// MissingKeyZero substitutes the reference with the zero value, which is
+	// an empty string in a clause and false in a predicate.
+	MissingKeyZero
+	// MissingKeyError stops the evaluation with an error naming the variable.
+	MissingKeyError
+)
+
+// Env is the environment in which a syntax tree is evaluated.
+type Env struct {
+	Vars       map[string]interface{}
+	MissingKey MissingKey
+	// Funcs are the functions which can be called in a pipeline, in addition
+	// to the built-in ones.
+	Funcs FuncMap
+	// Dialect determines how identifiers are quoted.
+	Dialect Dialect
+	// Bind, if set, binds the values of the variables substituted in the
+	// template, returning their placeholders. Raw values, and the ones of
+	// [raw], [ident], [oneof] and [dir] blocks, are inlined regardless.
+	Bind func(v interface{}) string
+	// Inline, if set, inlines the values of the variables as SQL literals of
+	// the Dialect, such as 'O''Reilly' or NULL, rather than as they're
+	// printed by fmt. It's ignored if Bind is set.
+	Inline bool
+	// Allowlists are the allowed values of the variables of [oneof] blocks
+	// which don't declare them in the template.
+	Allowlists map[string][]string
+	// Predicate, if set, decides the branches of the [if] blocks instead of
+	// their predicates. It's given the predicate as written in the template,
+	// such as .InStock or .Role | isAdmin, and returns whether the [then]
+	// clause is taken.
+	Predicate func(predicate string) bool
+}
+
+// lookup returns the value of the variable referenced by s. ok is false if s
+// should be left as-is, either because it's not a variable reference or
+// because the variable is missing and the policy says to leave it.
+func (e *Env) lookup(s string, zero interface{}) (v interface{}, ok bool, err error) {
+	if e == nil {
+		return nil, false, nil
+	}
+	if v, ok = e.Vars[s]; ok {
+		return v, true, nil
+	}
+	if !isVariable(s) {
+		return nil, false, nil
+	}
+
+	switch e.MissingKey {
+	case MissingKeyZero:
+		return zero, true, nil
+	case MissingKeyError:
+		return nil, false, errors.Errorf("no value given for variable %s", s)
+	}
+	return nil, false, nil
+}
+
+// format returns the value of a variable to be put in the query. It's the
+// placeholder of the bound value if the Env binds values, or the value as a
+// literal if it inlines them, unless the value is Raw. A driver.Valuer is
+// bound as-is, but otherwise replaced with its value. A nil value is NULL.
+func (e *Env) format(v interface{}) (string, error) {
+	if r, ok := v.(Raw); ok {
+		return string(r), nil
+	}
+	if e != nil && e.Bind != nil {
+		return e.Bind(v), nil
+	}
+	if e != nil && e.Inline {
+		return e.Dialect.Literal(v)
+	}
+	if _, isValuer := v.(driver.Valuer); isValuer {
+		dv, err := driverValue(v)
+		if err != nil {
+			return "", err
+		}
+		v = dv
+	}
+	if v == nil {
+		return "NULL", nil
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+// dialect returns the dialect of the Env, which is Postgres for a nil Env.
+func (e *Env) dialect() Dialect {
+	if e == nil {
+		return Postgres
+	}
+	return e.Dialect
+}
+
+// get returns the value of the variable, regardless of the missing key policy.
+func (e *Env) get(name string) (interface{}, bool) {
+	if e == nil {
+		return nil, false
+	}
+	v, ok := e.Vars[name]
+	return v, ok
+}
+
+// isZero checks if v is nil or the zero value of its type.
+func isZero(v interface{}) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
+
+// isVariable checks if s is a reference to a variable, such as .IncludeReviews.
+func isVariable(s string) bool {
+	if len(s) < 2 || s[0] != '.' {
+		return false
+	}
+	for i, r := range s[1:] {
+		isLetter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		isDigit := r >= '0' && r <= '9'
+		if !isLetter && !(isDigit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,244 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FuncMap is a map of functions which can be called in a pipeline, such as
+// {{ .Name | upper }}, by name.
+//
+// Like in the text/template package, a function must return either a single
+// value, or a value and an error. The value of the previous command of the
+// pipeline is passed as the last argument of the function.
+type FuncMap map[string]interface{}
+
+// builtins are the functions available in every template. The result of like
+// and quote depends on the Env, so Env.fn binds them to it.
+var builtins = FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"join":  join,
+	"like":  (*Env)(nil).like,
+	"quote": (*Env)(nil).quote,
+}
+
+// Builtins returns the functions available in every template.
+func Builtins() FuncMap {
+	funcs := make(FuncMap, len(builtins))
+	for name, fn := range builtins {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// join concatenates the elements of a slice, separated by sep.
+func join(sep string, elems interface{}) (string, error) {
+	v := reflect.ValueOf(elems)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", errors.Errorf("join expects a slice, got %T", elems)
+	}
+	ss := make([]string, v.Len())
+	for i := range ss {
+		ss[i] = fmt.Sprintf("%v", v.Index(i).Interface())
+	}
+	return strings.Join(ss, sep), nil
+}
+
+var (
+	likeReplacer = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	// SQL Server also treats [ as a wildcard, opening a set of characters.
+	sqlServerLikeReplacer = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `[`, `\[`)
+)
+
+// like returns the LIKE pattern matching any string containing s, followed by
+// the ESCAPE clause of the backslash escaping the wildcard characters in s,
+// such as '%50\%%' ESCAPE '\'. The pattern is bound if the Env binds values,
+// or quoted otherwise.
+func (e *Env) like(s string) Raw {
+	d := e.dialect()
+	replacer := likeReplacer
+	if d == SQLServer {
+		replacer = sqlServerLikeReplacer
+	}
+	pattern := "%" + replacer.Replace(s) + "%"
+	escape := " ESCAPE " + d.quoteString(`\`)
+	if e != nil && e.Bind != nil {
+		return Raw(e.Bind(pattern) + escape)
+	}
+	return Raw(d.quoteString(pattern) + escape)
+}
+
+// quote returns s as a single quoted string literal of the dialect.
+func (e *Env) quote(s string) Raw {
+	return Raw(e.dialect().quoteString(s))
+}
+
+// fn returns the function of the given name, looking up the functions of the
+// Env before the built-in ones.
+func (e *Env) fn(name string) (interface{}, bool) {
+	if e != nil {
+		if f, ok := e.Funcs[name]; ok {
+			return f, true
+		}
+	}
+	switch name {
+	case "like":
+		return e.like, true
+	case "quote":
+		return e.quote, true
+	}
+	f, ok := builtins[name]
+	return f, ok
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// CheckFuncs checks that the functions called in the tree are either built in
+// or defined in funcs, and that they can be called with the given args, as far
+// as it can be told before evaluation. The type of a variable is only known
+// at evaluation, for example.
+func (t *SyntaxTree) CheckFuncs(funcs FuncMap) error {
+	env := &Env{Funcs: funcs}
+	var err error
+	walk(t, func(n LanguageNode) {
+		if err != nil {
+			return
+		}
+		switch n := n.(type) {
+		case *Pipeline:
+			_, err = n.check(env)
+		case *IfBlock:
+			if n.PredicatePipe == nil {
+				return
+			}
+			var typ reflect.Type
+			typ, err = n.PredicatePipe.check(env)
+			if err == nil && typ != nil && typ.Kind() != reflect.Bool {
+				err = errors.Errorf("predicate must be a boolean expression, got %s", typ)
+			}
+		}
+	})
+	return err
+}
+
+// funcType returns the type of fn, checking that it's a function returning a
+// value and optionally an error.
+func funcType(name string, fn interface{}) (reflect.Type, error) {
+	ft := reflect.TypeOf(fn)
+	if ft == nil || ft.Kind() != reflect.Func {
+		return nil, errors.Errorf("%s is not a function", name)
+	}
+	if n := ft.NumOut(); n == 0 || n > 2 || (n == 2 && ft.Out(1) != errorType) {
+		return nil, errors.Errorf("function %s must return a value, and optionally an error", name)
+	}
+	return ft, nil
+}
+
+// paramTypes returns the types of the parameters of the function type ft, for
+// a call with n args. It returns an error if ft can't take n args.
+func paramTypes(name string, ft reflect.Type, n int) ([]reflect.Type, error) {
+	numIn := ft.NumIn()
+	if ft.IsVariadic() {
+		if n < numIn-1 {
+			return nil, errors.Errorf("wrong number of args for %s: want at least %d, got %d", name, numIn-1, n)
+		}
+	} else if n != numIn {
+		return nil, errors.Errorf("wrong number of args for %s: want %d, got %d", name, numIn, n)
+	}
+
+	types := make([]reflect.Type, n)
+	for i := range types {
+		if ft.IsVariadic() && i >= numIn-1 {
+			types[i] = ft.In(numIn - 1).Elem()
+		} else {
+			types[i] = ft.In(i)
+		}
+	}
+	return types, nil
+}
+
+// call calls the function of the given name with args, converting them to
+// the types of the function's parameters where needed.
+func call(name string, fn interface{}, args []interface{}) (interface{}, error) {
+	ft, err := funcType(name, fn)
+	if err != nil {
+		return nil, err
+	}
+	types, err := paramTypes(name, ft, len(args))
+	if err != nil {
+		return nil, err
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		v, err := convertArg(arg, types[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "argument %d of %s", i+1, name)
+		}
+		in[i] = v
+	}
+
+	out := reflect.ValueOf(fn).Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, errors.Wrapf(out[1].Interface().(error), "calling %s", name)
+	}
+	return out[0].Interface(), nil
+}
+
+// checkCall checks that the function of the given name can be called with
+// args of the given types, and returns the type of its result. A nil type
+// stands for an arg whose type is only known at evaluation.
+func checkCall(name string, fn interface{}, argTypes []reflect.Type) (reflect.Type, error) {
+	ft, err := funcType(name, fn)
+	if err != nil {
+		return nil, err
+	}
+	types, err := paramTypes(name, ft, len(argTypes))
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range argTypes {
+		if t != nil && !isConvertible(t, types[i]) {
+			return nil, errors.Errorf("argument %d of %s: expected %s, got %s", i+1, name, types[i], t)
+		}
+	}
+	return ft.Out(0), nil
+}
+
+// convertArg converts arg to a value of type t, if it's assignable or if both
+// are numbers. nil is converted to the zero value of t.
+func convertArg(arg interface{}, t reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(t), nil
+	}
+
+	v := reflect.ValueOf(arg)
+	if !isConvertible(v.Type(), t) {
+		return reflect.Value{}, errors.Errorf("expected %s, got %T", t, arg)
+	}
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	return v.Convert(t), nil
+}
+
+// isConvertible checks if a value of type from can be passed as an arg of
+// type to, either because it's assignable or because both are numbers.
+func isConvertible(from, to reflect.Type) bool {
+	return from.AssignableTo(to) || (isNumberKind(from.Kind()) && isNumberKind(to.Kind()))
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
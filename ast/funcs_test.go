@@ -0,0 +1,91 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestSyntaxTree_CheckFuncs(t *testing.T) {
+	funcs := FuncMap{
+		"isAdmin": func(role string) bool { return role == "admin" },
+		"tenant":  func(id int, table string) string { return table },
+		"any":     func(v interface{}) interface{} { return v },
+		"bad":     func() {},
+		"notFunc": 1,
+	}
+	cases := []struct {
+		desc    string
+		input   string
+		isError bool
+	}{
+		{
+			desc:  "Valid calls",
+			input: `{{ .Table | tenant 1 | upper }} {{ [if] .Role | isAdmin [then] {{ "x" | any | upper }} }}`,
+		},
+		{
+			desc:  "Variable args are checked at evaluation",
+			input: `{{ .Table | tenant .TenantID }}`,
+		},
+		{
+			desc:    "Undefined function",
+			input:   `{{ .Name | nope }}`,
+			isError: true,
+		},
+		{
+			desc:    "Undefined function in a branch",
+			input:   `{{ [if] .A [then] ABC [else] {{ .Name | nope }} }}`,
+			isError: true,
+		},
+		{
+			desc:    "Wrong number of args",
+			input:   `{{ .Table | tenant }}`,
+			isError: true,
+		},
+		{
+			desc:    "Wrong type of constant arg",
+			input:   `{{ .Table | tenant "1" }}`,
+			isError: true,
+		},
+		{
+			desc:    "Wrong type of piped value",
+			input:   `{{ .Role | isAdmin | upper }}`,
+			isError: true,
+		},
+		{
+			desc:    "Non-boolean predicate",
+			input:   `{{ [if] .Role | upper [then] ABC }}`,
+			isError: true,
+		},
+		{
+			desc:    "Function without a result",
+			input:   `{{ .Name | bad }}`,
+			isError: true,
+		},
+		{
+			desc:    "Not a function",
+			input:   `{{ .Name | notFunc }}`,
+			isError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			st, err := tt.Parse()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			err = st.(*SyntaxTree).CheckFuncs(funcs)
+			if err != nil {
+				if !c.isError {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			} else if c.isError {
+				t.Fatalf("Expected error but got nil error")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package ast
+
+import (
+	"github.com/pkg/errors"
+)
+
+const keywordIdent = "[ident]"
+
+// IdentBlock represents a parsed syntax state of an [ident] block, which
+// evaluates to the value of the variable quoted as an identifier, such as a
+// dynamic table or column name.
+type IdentBlock struct {
+	Name string
+}
+
+// Evaluate returns the value of the variable quoted as an identifier in the
+// dialect of the Env. It returns an error if the value isn't a valid
+// identifier, so it can't be used to inject arbitrary SQL.
+func (ib *IdentBlock) Evaluate(env *Env) (string, error) {
+	if ib == nil {
+		return "", nil
+	}
+	v, ok, err := env.lookup(ib.Name, nil)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return ib.Name, nil
+	}
+	if v, err = driverValue(v); err != nil {
+		return "", err
+	}
+	if v == nil {
+		return "", nil
+	}
+
+	s, isString := v.(string)
+	if !isString {
+		return "", errors.Errorf("identifier %s must be a string, got %T", ib.Name, v)
+	}
+	var d Dialect
+	if env != nil {
+		d = env.Dialect
+	}
+	q, err := d.QuoteIdent(s)
+	if err != nil {
+		return "", errors.Wrapf(err, "quoting %s", ib.Name)
+	}
+	return q, nil
+}
+
+// Variables returns the variable of this IdentBlock.
+func (ib *IdentBlock) Variables() []Variable {
+	if ib == nil {
+		return nil
+	}
+	return []Variable{{Name: ib.Name, Value: true}}
+}
+
+// isIdentBlock checks if the TokenTree is analyzed to an ident block.
+func isIdentBlock(tt *TokenTree) (bool, error) {
+	if len(tt.chunks) == 0 {
+		return false, errors.New("expression with empty chunks")
+	}
+
+	if maybeIdent, ok := tt.chunks[0].(*Literal); !ok || maybeIdent.String() != keywordIdent {
+		return false, nil
+	}
+
+	if len(tt.chunks) != 2 {
+		return false, errors.New("[ident] must be followed by a single variable")
+	}
+	if name, ok := tt.chunks[1].(*Literal); !ok || !isVariable(name.String()) {
+		return false, errors.New("[ident] must be followed by a single variable")
+	}
+
+	return true, nil
+}
+
+// parseIdentBlock parses the TokenTree and returns the parsed IdentBlock.
+// It assumes the TokenTree is a valid ident block (make sure to call
+// isIdentBlock first).
+func parseIdentBlock(tt *TokenTree) *IdentBlock {
+	return &IdentBlock{Name: tt.chunks[1].(*Literal).String()}
+}
@@ -0,0 +1,101 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestSyntaxTree_EvaluateIdent(t *testing.T) {
+	cases := []struct {
+		desc         string
+		input        string
+		inputEnv     *Env
+		expected     string
+		isParseError bool
+		isError      bool
+	}{
+		{
+			desc:     "Postgres",
+			input:    `SELECT * FROM {{ [ident] .Table }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Table": "tenant_42.products"}},
+			expected: `SELECT * FROM "tenant_42"."products"`,
+		},
+		{
+			desc:     "MySQL",
+			input:    `ORDER BY {{ [ident] .Sort }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": "price"}, Dialect: MySQL},
+			expected: "ORDER BY `price`",
+		},
+		{
+			desc:     "SQLServer",
+			input:    `ORDER BY {{ [ident] .Sort }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": "price"}, Dialect: SQLServer},
+			expected: "ORDER BY [price]",
+		},
+		{
+			desc:     "Missing variable is left as-is",
+			input:    `ORDER BY {{ [ident] .Sort }}`,
+			inputEnv: &Env{},
+			expected: "ORDER BY .Sort",
+		},
+		{
+			desc:     "Missing variable is removed",
+			input:    `ORDER BY {{ [ident] .Sort }}`,
+			inputEnv: &Env{MissingKey: MissingKeyZero},
+			expected: "ORDER BY",
+		},
+		{
+			desc:     "Invalid characters",
+			input:    `ORDER BY {{ [ident] .Sort }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": "price; DROP TABLE products"}},
+			isError:  true,
+		},
+		{
+			desc:     "Not a string",
+			input:    `ORDER BY {{ [ident] .Sort }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Sort": 1}},
+			isError:  true,
+		},
+		{
+			desc:         "Not a variable",
+			input:        `ORDER BY {{ [ident] price }}`,
+			isParseError: true,
+		},
+		{
+			desc:         "More than one variable",
+			input:        `ORDER BY {{ [ident] .Sort .Dir }}`,
+			isParseError: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			st, err := tt.Parse()
+			if c.isParseError {
+				if err == nil {
+					t.Error("Expected parse error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := st.Evaluate(c.inputEnv)
+			if c.isError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if result != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package ast
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	keywordDefine  = "[define]"
+	keywordInclude = "[include]"
+)
+
+var templateNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+// DefineBlock represents a parsed syntax state of a [define] block, which
+// defines a named partial to be included elsewhere. It evaluates to an empty
+// string.
+type DefineBlock struct {
+	Name string
+	Body *SyntaxTree
+}
+
+// Evaluate returns an empty string, since a [define] block only defines the
+// partial.
+func (db *DefineBlock) Evaluate(*Env) (string, error) {
+	return "", nil
+}
+
+// Variables returns nothing, since the variables of a partial are only
+// referenced where it's included.
+func (db *DefineBlock) Variables() []Variable {
+	return nil
+}
+
+// IncludeBlock represents a parsed syntax state of an [include] block, which
+// evaluates to the partial of the given name.
+type IncludeBlock struct {
+	Name string
+	// Tree is the included partial, set by ResolveIncludes.
+	Tree *SyntaxTree
+}
+
+// Evaluate returns the evaluated partial.
+func (ib *IncludeBlock) Evaluate(env *Env) (string, error) {
+	if ib == nil {
+		return "", nil
+	}
+	if ib.Tree == nil {
+		return "", errors.Errorf("template %s is not resolved", ib.Name)
+	}
+	return ib.Tree.Evaluate(env)
+}
+
+// Variables returns the variables referenced in the included partial.
+func (ib *IncludeBlock) Variables() []Variable {
+	if ib == nil {
+		return nil
+	}
+	return ib.Tree.Variables()
+}
+
+// isDefineBlock checks if the TokenTree is analyzed to a define block.
+func isDefineBlock(tt *TokenTree) (bool, error) {
+	if len(tt.chunks) == 0 {
+		return false, errors.New("expression with empty chunks")
+	}
+
+	if maybeDefine, ok := tt.chunks[0].(*Literal); !ok || maybeDefine.String() != keywordDefine {
+		return false, nil
+	}
+
+	if tt.parent != nil && tt.parent.parent != nil {
+		return false, errors.New("[define] must be at the top level of the template")
+	}
+	if len(tt.chunks) < 2 {
+		return false, errors.New("[define] must be followed by a name")
+	}
+	if name, ok := tt.chunks[1].(*Literal); !ok || !templateNameRegex.MatchString(name.String()) {
+		return false, errors.New("[define] must be followed by a name")
+	}
+
+	return true, nil
+}
+
+// parseDefineBlock parses the TokenTree and returns the parsed DefineBlock.
+// It assumes the TokenTree is a valid define block (make sure to call
+// isDefineBlock first).
+func parseDefineBlock(tt *TokenTree) (*DefineBlock, error) {
+	db := &DefineBlock{
+		Name: tt.chunks[1].(*Literal).String(),
+		Body: &SyntaxTree{},
+	}
+	for _, chunk := range tt.chunks[2:] {
+		switch c := chunk.(type) {
+		case *TokenTree:
+			node, err := c.Parse()
+			if err != nil {
+				return nil, errors.Wrapf(err, "parsing template %s", db.Name)
+			}
+			db.Body.Nodes = append(db.Body.Nodes, node)
+		case *Literal:
+			db.Body.Nodes = append(db.Body.Nodes, c)
+		}
+	}
+	return db, nil
+}
+
+// isIncludeBlock checks if the TokenTree is analyzed to an include block.
+func isIncludeBlock(tt *TokenTree) (bool, error) {
+	if len(tt.chunks) == 0 {
+		return false, errors.New("expression with empty chunks")
+	}
+
+	if maybeInclude, ok := tt.chunks[0].(*Literal); !ok || maybeInclude.String() != keywordInclude {
+		return false, nil
+	}
+
+	if len(tt.chunks) != 2 {
+		return false, errors.New("[include] must be followed by a single name")
+	}
+	if name, ok := tt.chunks[1].(*Literal); !ok || !templateNameRegex.MatchString(name.String()) {
+		return false, errors.New("[include] must be followed by a single name")
+	}
+
+	return true, nil
+}
+
+// parseIncludeBlock parses the TokenTree and returns the parsed IncludeBlock.
+// It assumes the TokenTree is a valid include block (make sure to call
+// isIncludeBlock first).
+func parseIncludeBlock(tt *TokenTree) *IncludeBlock {
+	return &IncludeBlock{Name: tt.chunks[1].(*Literal).String()}
+}
+
+// Defines returns the partials defined in the [define] blocks of the tree, by
+// name. It returns an error if a partial is defined more than once.
+func (t *SyntaxTree) Defines() (map[string]*SyntaxTree, error) {
+	defines := make(map[string]*SyntaxTree)
+	if t == nil {
+		return defines, nil
+	}
+	for _, node := range t.Nodes {
+		var db *DefineBlock
+		switch n := node.(type) {
+		case *DefineBlock:
+			db = n
+		case *SyntaxTree:
+			// An expression is parsed into a SyntaxTree of its own.
+			if len(n.Nodes) == 1 {
+				db, _ = n.Nodes[0].(*DefineBlock)
+			}
+		}
+		if db == nil {
+			continue
+		}
+		if _, ok := defines[db.Name]; ok {
+			return nil, errors.Errorf("template %s is defined more than once", db.Name)
+		}
+		defines[db.Name] = db.Body
+	}
+	return defines, nil
+}
+
+// ResolveIncludes resolves the [include] blocks of the tree, and of the
+// partials, to the partials of the same names. It returns an error if a
+// partial isn't found, or if partials include each other in a cycle.
+func (t *SyntaxTree) ResolveIncludes(partials map[string]*SyntaxTree) error {
+	r := newResolver(partials)
+	if err := r.resolveAll(); err != nil {
+		return err
+	}
+	return r.resolve(t)
+}
+
+// ResolveIncludes resolves the [include] blocks of the partials, which may
+// include each other, to the partials of the same names. It returns an error
+// if a partial isn't found, or if partials include each other in a cycle.
+func ResolveIncludes(partials map[string]*SyntaxTree) error {
+	return newResolver(partials).resolveAll()
+}
+
+// resolver resolves [include] blocks, keeping track of the partials being
+// resolved to detect cycles.
+type resolver struct {
+	partials map[string]*SyntaxTree
+	resolved map[string]bool
+	// stack are the names of the partials being resolved, outermost first.
+	stack []string
+}
+
+func newResolver(partials map[string]*SyntaxTree) *resolver {
+	return &resolver{
+		partials: partials,
+		resolved: make(map[string]bool),
+	}
+}
+
+// resolveAll resolves all the partials, in the order of their names so the
+// errors are deterministic.
+func (r *resolver) resolveAll() error {
+	names := make([]string, 0, len(r.partials))
+	for name := range r.partials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := r.partial(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *resolver) resolve(t *SyntaxTree) error {
+	var err error
+	walk(t, func(n LanguageNode) {
+		ib, ok := n.(*IncludeBlock)
+		if !ok || err != nil {
+			return
+		}
+		ib.Tree, err = r.partial(ib.Name)
+	})
+	return err
+}
+
+// partial returns the partial of the given name, resolving its own includes.
+func (r *resolver) partial(name string) (*SyntaxTree, error) {
+	p, ok := r.partials[name]
+	if !ok {
+		return nil, errors.Errorf("template %s is not defined", name)
+	}
+	if r.resolved[name] {
+		return p, nil
+	}
+	for i, s := range r.stack {
+		if s == name {
+			cycle := append(append([]string{}, r.stack[i:]...), name)
+			return nil, errors.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	r.stack = append(r.stack, name)
+	if err := r.resolve(p); err != nil {
+		return nil, err
+	}
+	r.stack = r.stack[:len(r.stack)-1]
+	r.resolved[name] = true
+	return p, nil
+}
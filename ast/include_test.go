@@ -0,0 +1,119 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyntaxTree_ResolveIncludes(t *testing.T) {
+	cases := []struct {
+		desc          string
+		input         string
+		inputVars     map[string]interface{}
+		expected      string
+		expectedError string
+	}{
+		{
+			desc: "Include a partial",
+			input: `{{ [define] active deleted_at IS NULL AND stock > .MinStock }}
+				SELECT * FROM products WHERE {{ [include] active }}`,
+			inputVars: map[string]interface{}{".MinStock": 0},
+			expected:  "SELECT * FROM products WHERE deleted_at IS NULL AND stock > 0",
+		},
+		{
+			desc: "Nested includes and expressions",
+			input: `{{ [define] joins JOIN reviews ON reviews.product_id = products.id }}
+				{{ [define] select SELECT * FROM products {{ [if] .Reviews [then] {{ [include] joins }} }} }}
+				{{ [include] select }} LIMIT 1`,
+			inputVars: map[string]interface{}{".Reviews": true},
+			expected:  "SELECT * FROM products JOIN reviews ON reviews.product_id = products.id LIMIT 1",
+		},
+		{
+			desc:          "Undefined partial",
+			input:         `SELECT * FROM products WHERE {{ [include] active }}`,
+			expectedError: "template active is not defined",
+		},
+		{
+			desc:          "Include itself",
+			input:         `{{ [define] a x {{ [include] a }} }} {{ [include] a }}`,
+			expectedError: "include cycle: a -> a",
+		},
+		{
+			desc: "Include cycle",
+			input: `{{ [define] a x {{ [include] b }} }}
+				{{ [define] b y {{ [include] c }} }}
+				{{ [define] c z {{ [include] a }} }}
+				{{ [include] c }}`,
+			expectedError: "include cycle: a -> b -> c -> a",
+		},
+		{
+			desc:          "Defined twice",
+			input:         `{{ [define] a x }} {{ [define] a y }}`,
+			expectedError: "template a is defined more than once",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			node, err := tt.Parse()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			st := node.(*SyntaxTree)
+
+			defines, err := st.Defines()
+			if err == nil {
+				err = st.ResolveIncludes(defines)
+			}
+			if c.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), c.expectedError) {
+					t.Errorf("Expected error %q, got %v", c.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := st.Evaluate(&Env{Vars: c.inputVars})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if result != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, result)
+			}
+
+			vars := st.Variables()
+			for name := range c.inputVars {
+				found := false
+				for _, v := range vars {
+					found = found || v.Name == name
+				}
+				if !found {
+					t.Errorf("Expected variable %s in %v", name, vars)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenTree_ParseDefine(t *testing.T) {
+	for _, input := range []string{
+		`{{ [if] .A [then] {{ [define] a x }} }}`,
+		`{{ [define] }}`,
+		`{{ [define] "a b" x }}`,
+		`{{ [include] a b }}`,
+		`{{ [define] a {{ [params] .X }} }}`,
+	} {
+		tt, err := BuildTokenTree(input)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err = tt.Parse(); err == nil {
+			t.Errorf("Expected error parsing %s, got nil", input)
+		}
+	}
+}
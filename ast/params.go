@@ -0,0 +1,241 @@
+package ast
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const keywordParams = "[params]"
+
+// Param is a parameter declared in a [params] block of the template, such as
+// .Category:string! or .Limit:int=50.
+type Param struct {
+	Name string
+	// Type is one of "string", "int", "float", "bool", or "any" if the type
+	// isn't declared.
+	Type string
+	// Required is true if the parameter is declared with a trailing "!".
+	Required bool
+	// Default is the declared default value, if any.
+	Default interface{}
+}
+
+var paramRegex = regexp.MustCompile(`^(\.[A-Za-z_][A-Za-z0-9_]*)(?::([a-z]+))?(?:(!)|=(.+))?$`)
+
+// parseParam parses the declaration of a parameter.
+func parseParam(s string) (Param, error) {
+	m := paramRegex.FindStringSubmatch(s)
+	if m == nil {
+		return Param{}, errors.Errorf("invalid parameter declaration %q", s)
+	}
+
+	p := Param{
+		Name:     m[1],
+		Type:     m[2],
+		Required: m[3] != "",
+	}
+	if p.Type == "" {
+		p.Type = "any"
+	}
+	if _, ok := paramZeroValues[p.Type]; !ok {
+		return Param{}, errors.Errorf("unknown type %q of parameter %s", p.Type, p.Name)
+	}
+
+	if m[4] != "" {
+		v, err := parseParamValue(p.Type, m[4])
+		if err != nil {
+			return Param{}, errors.Wrapf(err, "parsing default value of parameter %s", p.Name)
+		}
+		p.Default = v
+	}
+
+	return p, nil
+}
+
+var paramZeroValues = map[string]interface{}{
+	"any":    nil,
+	"string": "",
+	"int":    0,
+	"float":  0.0,
+	"bool":   false,
+}
+
+func parseParamValue(typ, s string) (interface{}, error) {
+	switch typ {
+	case "int":
+		return strconv.Atoi(s)
+	case "float":
+		return strconv.ParseFloat(s, 64)
+	case "bool":
+		return strconv.ParseBool(s)
+	}
+	return s, nil
+}
+
+// Resolve returns the value of the parameter, given the value of the variable
+// and whether it's given at all. It returns an error if a required parameter
+// isn't given, or if the value doesn't match the declared type.
+// For a parameter which isn't given, it returns the default value, or the
+// zero value of the type if there's no default.
+func (p Param) Resolve(v interface{}, ok bool) (interface{}, error) {
+	// A driver.Valuer is checked by its value, but resolves to itself.
+	dv, err := driverValue(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving parameter %s", p.Name)
+	}
+	if !ok || dv == nil {
+		if p.Required {
+			return nil, errors.Errorf("missing required parameter %s", p.Name)
+		}
+		if p.Default != nil {
+			return p.Default, nil
+		}
+		return paramZeroValues[p.Type], nil
+	}
+
+	if !p.matchesType(dv) {
+		return nil, errors.Errorf("parameter %s must be %s, got %T", p.Name, p.Type, v)
+	}
+	if p.Default != nil && isZero(dv) {
+		return p.Default, nil
+	}
+	return v, nil
+}
+
+// matchesType checks if v is a value of the parameter's type.
+func (p Param) matchesType(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	switch p.Type {
+	case "string":
+		return rv.Kind() == reflect.String
+	case "bool":
+		return rv.Kind() == reflect.Bool
+	case "int":
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		case reflect.Float32, reflect.Float64:
+			// Numbers decoded from JSON are floats, so accept whole ones.
+			return rv.Float() == math.Trunc(rv.Float())
+		}
+		return false
+	case "float":
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// String returns the declaration of the parameter as written in the template.
+func (p Param) String() string {
+	s := p.Name
+	if p.Type != "any" {
+		s += ":" + p.Type
+	}
+	if p.Required {
+		s += "!"
+	} else if p.Default != nil {
+		s += fmt.Sprintf("=%v", p.Default)
+	}
+	return s
+}
+
+// ParamsBlock represents a parsed syntax state of a [params] block, which
+// declares the parameters of the template. It evaluates to an empty string.
+type ParamsBlock struct {
+	Params []Param
+}
+
+// Evaluate returns an empty string, since a [params] block only declares the
+// parameters.
+func (pb *ParamsBlock) Evaluate(*Env) (string, error) {
+	return "", nil
+}
+
+// Variables returns nothing, since declaring a parameter doesn't reference it.
+func (pb *ParamsBlock) Variables() []Variable {
+	return nil
+}
+
+// isParamsBlock checks if the TokenTree is analyzed to a params block.
+func isParamsBlock(tt *TokenTree) (bool, error) {
+	if len(tt.chunks) == 0 {
+		return false, errors.New("expression with empty chunks")
+	}
+
+	if maybeParams, ok := tt.chunks[0].(*Literal); !ok || maybeParams.String() != keywordParams {
+		return false, nil
+	}
+
+	if tt.parent != nil && tt.parent.parent != nil {
+		return false, errors.New("[params] must be at the top level of the template")
+	}
+	for _, chunk := range tt.chunks[1:] {
+		if _, ok := chunk.(*Literal); !ok {
+			return false, errors.New("[params] must be followed by parameter declarations only")
+		}
+	}
+
+	return true, nil
+}
+
+// parseParamsBlock parses the TokenTree and returns the parsed ParamsBlock.
+// It assumes the TokenTree is a valid params block (make sure to call
+// isParamsBlock first).
+func parseParamsBlock(tt *TokenTree) (*ParamsBlock, error) {
+	pb := &ParamsBlock{}
+	for _, chunk := range tt.chunks[1:] {
+		p, err := parseParam(chunk.(*Literal).String())
+		if err != nil {
+			return nil, err
+		}
+		pb.Params = append(pb.Params, p)
+	}
+	return pb, nil
+}
+
+// Params returns the parameters declared in the [params] blocks of the tree.
+// It returns an error if a parameter is declared more than once.
+func (t *SyntaxTree) Params() ([]Param, error) {
+	if t == nil {
+		return nil, nil
+	}
+	var (
+		params []Param
+		seen   = make(map[string]bool)
+	)
+	for _, node := range t.Nodes {
+		var pb *ParamsBlock
+		switch n := node.(type) {
+		case *ParamsBlock:
+			pb = n
+		case *SyntaxTree:
+			// An expression is parsed into a SyntaxTree of its own.
+			if len(n.Nodes) == 1 {
+				pb, _ = n.Nodes[0].(*ParamsBlock)
+			}
+		}
+		if pb == nil {
+			continue
+		}
+		for _, p := range pb.Params {
+			if seen[p.Name] {
+				return nil, errors.Errorf("parameter %s is declared more than once", p.Name)
+			}
+			seen[p.Name] = true
+			params = append(params, p)
+		}
+	}
+	return params, nil
+}
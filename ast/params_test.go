@@ -0,0 +1,213 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestParseParam(t *testing.T) {
+	cases := []struct {
+		desc     string
+		input    string
+		isError  bool
+		expected Param
+	}{
+		{
+			desc:     "Untyped",
+			input:    ".Category",
+			expected: Param{Name: ".Category", Type: "any"},
+		},
+		{
+			desc:     "Required",
+			input:    ".Category:string!",
+			expected: Param{Name: ".Category", Type: "string", Required: true},
+		},
+		{
+			desc:     "Default int",
+			input:    ".Limit:int=50",
+			expected: Param{Name: ".Limit", Type: "int", Default: 50},
+		},
+		{
+			desc:     "Default bool",
+			input:    ".Desc:bool=true",
+			expected: Param{Name: ".Desc", Type: "bool", Default: true},
+		},
+		{
+			desc:     "Default string",
+			input:    ".Sort:string=created_at",
+			expected: Param{Name: ".Sort", Type: "string", Default: "created_at"},
+		},
+		{
+			desc:    "Not a variable",
+			input:   "Category:string",
+			isError: true,
+		},
+		{
+			desc:    "Unknown type",
+			input:   ".Category:text",
+			isError: true,
+		},
+		{
+			desc:    "Invalid default",
+			input:   ".Limit:int=fifty",
+			isError: true,
+		},
+		{
+			desc:    "Required with default",
+			input:   ".Limit:int!=50",
+			isError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			output, err := parseParam(c.input)
+			if err != nil {
+				if !c.isError {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			} else if c.isError {
+				t.Fatalf("Expected error but got nil error")
+			}
+			if diff := deep.Equal(c.expected, output); diff != nil {
+				t.Errorf("Wrong result: %v", diff)
+			}
+			if output.String() != c.input {
+				t.Errorf("Expected %v but got %v", c.input, output.String())
+			}
+		})
+	}
+}
+
+func TestParam_Resolve(t *testing.T) {
+	cases := []struct {
+		desc       string
+		inputParam Param
+		inputValue interface{}
+		inputOk    bool
+		isError    bool
+		expected   interface{}
+	}{
+		{
+			desc:       "Missing required",
+			inputParam: Param{Name: ".Category", Type: "string", Required: true},
+			isError:    true,
+		},
+		{
+			desc:       "Nil required",
+			inputParam: Param{Name: ".Category", Type: "any", Required: true},
+			inputOk:    true,
+			isError:    true,
+		},
+		{
+			desc:       "Given required",
+			inputParam: Param{Name: ".Category", Type: "string", Required: true},
+			inputValue: "books",
+			inputOk:    true,
+			expected:   "books",
+		},
+		{
+			desc:       "Wrong type",
+			inputParam: Param{Name: ".Limit", Type: "int"},
+			inputValue: "10",
+			inputOk:    true,
+			isError:    true,
+		},
+		{
+			desc:       "Whole float for int",
+			inputParam: Param{Name: ".Limit", Type: "int"},
+			inputValue: 10.0,
+			inputOk:    true,
+			expected:   10.0,
+		},
+		{
+			desc:       "Fractional float for int",
+			inputParam: Param{Name: ".Limit", Type: "int"},
+			inputValue: 10.5,
+			inputOk:    true,
+			isError:    true,
+		},
+		{
+			desc:       "Missing with default",
+			inputParam: Param{Name: ".Limit", Type: "int", Default: 50},
+			expected:   50,
+		},
+		{
+			desc:       "Zero with default",
+			inputParam: Param{Name: ".Limit", Type: "int", Default: 50},
+			inputValue: uint(0),
+			inputOk:    true,
+			expected:   50,
+		},
+		{
+			desc:       "Missing without default",
+			inputParam: Param{Name: ".IncludeReviews", Type: "bool"},
+			expected:   false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			output, err := c.inputParam.Resolve(c.inputValue, c.inputOk)
+			if err != nil {
+				if !c.isError {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			} else if c.isError {
+				t.Fatalf("Expected error but got nil error")
+			}
+			if diff := deep.Equal(c.expected, output); diff != nil {
+				t.Errorf("Wrong result: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSyntaxTree_Params(t *testing.T) {
+	tt, err := BuildTokenTree(`
+		{{ [params] .Category:string! .Limit:int=50 }}
+		SELECT * FROM products WHERE category = .Category
+		{{ [params] .IncludeReviews:bool }}
+		LIMIT .Limit
+	`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	st, err := tt.Parse()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	params, err := st.(*SyntaxTree).Params()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Param{
+		{Name: ".Category", Type: "string", Required: true},
+		{Name: ".Limit", Type: "int", Default: 50},
+		{Name: ".IncludeReviews", Type: "bool"},
+	}
+	if diff := deep.Equal(expected, params); diff != nil {
+		t.Errorf("Wrong result: %v", diff)
+	}
+
+	for _, q := range []string{
+		`{{ [params] .Limit:int }} {{ [params] .Limit:int }}`,
+		`{{ [if] .A [then] {{ [params] .Limit:int }} }}`,
+		`{{ [params] {{ .Limit:int }} }}`,
+	} {
+		tt, err := BuildTokenTree(q)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		st, err := tt.Parse()
+		if err == nil {
+			_, err = st.(*SyntaxTree).Params()
+		}
+		if err == nil {
+			t.Errorf("Expected error for %q", q)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const pipeSeparator = "|"
+
+// Pipeline represents a parsed syntax state of a pipeline, such as
+// {{ .Cols | join ", " }}. The value of the operand is passed through each
+// command of the pipeline in turn.
+type Pipeline struct {
+	Operand Operand
+	Cmds    []Command
+}
+
+// Command is a call of a function in a pipeline.
+type Command struct {
+	Name string
+	Args []Operand
+}
+
+// Operand is either a reference to a variable or a constant value.
+type Operand struct {
+	// Variable is the name of the variable, or empty for a constant.
+	Variable string
+	// Value is the constant, which is a string, a bool, an int or a float64.
+	Value interface{}
+}
+
+// Evaluate returns the evaluated value of the pipeline as a string.
+func (p *Pipeline) Evaluate(env *Env) (string, error) {
+	if p == nil {
+		return "", nil
+	}
+	v, err := p.value(env)
+	if err != nil {
+		return "", err
+	}
+	return env.format(v)
+}
+
+// value returns the value of the pipeline.
+func (p *Pipeline) value(env *Env) (interface{}, error) {
+	v, err := p.Operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range p.Cmds {
+		fn, ok := env.fn(cmd.Name)
+		if !ok {
+			return nil, errors.Errorf("function %s is not defined", cmd.Name)
+		}
+		args := make([]interface{}, 0, len(cmd.Args)+1)
+		for _, arg := range cmd.Args {
+			a, err := arg.eval(env)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+		}
+		if v, err = call(cmd.Name, fn, append(args, v)); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// Variables returns the variables referenced in the pipeline.
+func (p *Pipeline) Variables() []Variable {
+	if p == nil {
+		return nil
+	}
+	var vars []Variable
+	if p.Operand.Variable != "" {
+		vars = append(vars, Variable{Name: p.Operand.Variable, Piped: true, Value: true})
+	}
+	for _, cmd := range p.Cmds {
+		for _, arg := range cmd.Args {
+			if arg.Variable != "" {
+				vars = append(vars, Variable{Name: arg.Variable, Piped: true, Value: true})
+			}
+		}
+	}
+	return vars
+}
+
+// String returns the pipeline as written in the template.
+func (p *Pipeline) String() string {
+	words := []string{p.Operand.String()}
+	for _, cmd := range p.Cmds {
+		words = append(words, pipeSeparator, cmd.Name)
+		for _, arg := range cmd.Args {
+			words = append(words, arg.String())
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// check checks the calls of the pipeline against the functions of env, as far
+// as possible before evaluation, and returns the type of the pipeline's
+// value. The type is nil if it's only known at evaluation.
+func (p *Pipeline) check(env *Env) (reflect.Type, error) {
+	t := p.Operand.typ()
+	for _, cmd := range p.Cmds {
+		fn, ok := env.fn(cmd.Name)
+		if !ok {
+			return nil, errors.Errorf("function %s is not defined", cmd.Name)
+		}
+		argTypes := make([]reflect.Type, 0, len(cmd.Args)+1)
+		for _, arg := range cmd.Args {
+			argTypes = append(argTypes, arg.typ())
+		}
+		var err error
+		if t, err = checkCall(cmd.Name, fn, append(argTypes, t)); err != nil {
+			return nil, err
+		}
+		if t.Kind() == reflect.Interface {
+			t = nil
+		}
+	}
+	return t, nil
+}
+
+// String returns the operand as written in the template.
+func (o Operand) String() string {
+	if o.Variable != "" {
+		return o.Variable
+	}
+	if s, ok := o.Value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", o.Value)
+}
+
+// typ returns the type of the operand, or nil if it's a variable.
+func (o Operand) typ() reflect.Type {
+	if o.Variable != "" {
+		return nil
+	}
+	return reflect.TypeOf(o.Value)
+}
+
+// eval returns the value of the operand. A missing variable evaluates to nil
+// with the MissingKeyZero policy, which is converted to the zero value of the
+// parameter it's passed to.
+func (o Operand) eval(env *Env) (interface{}, error) {
+	if o.Variable == "" {
+		return o.Value, nil
+	}
+	v, ok, err := env.lookup(o.Variable, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return o.Variable, nil
+	}
+	return v, nil
+}
+
+// isPipeline checks if the TokenTree is analyzed to a pipeline.
+func isPipeline(tt *TokenTree) (bool, error) {
+	if len(tt.chunks) == 0 {
+		return false, errors.New("expression with empty chunks")
+	}
+
+	var hasPipe bool
+	for _, chunk := range tt.chunks {
+		if l, ok := chunk.(*Literal); ok && l.String() == pipeSeparator {
+			hasPipe = true
+		}
+	}
+	if !hasPipe {
+		return false, nil
+	}
+
+	for _, chunk := range tt.chunks {
+		if l, ok := chunk.(*Literal); !ok || IsKeyword(l.String()) {
+			return false, errors.New("pipeline must consist of plain words")
+		}
+	}
+
+	return true, nil
+}
+
+// parsePipeline parses the TokenTree and returns the parsed pipeline.
+// It assumes the TokenTree is a valid pipeline (make sure to call isPipeline
+// first).
+func parsePipeline(tt *TokenTree) (*Pipeline, error) {
+	var (
+		segments [][]string
+		segment  []string
+	)
+	for _, chunk := range tt.chunks {
+		s := chunk.(*Literal).String()
+		if s == pipeSeparator {
+			segments = append(segments, segment)
+			segment = nil
+			continue
+		}
+		segment = append(segment, s)
+	}
+	segments = append(segments, segment)
+
+	operands, err := parseOperands(segments[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(operands) != 1 {
+		return nil, errors.New("pipeline must start with a single variable or value")
+	}
+
+	p := &Pipeline{Operand: operands[0]}
+	for _, segment := range segments[1:] {
+		if len(segment) == 0 {
+			return nil, errors.New("missing function name in pipeline")
+		}
+		if _, err := parseOperand(segment[0]); err == nil {
+			return nil, errors.Errorf("expected a function name in pipeline, got %s", segment[0])
+		}
+		args, err := parseOperands(segment[1:])
+		if err != nil {
+			return nil, err
+		}
+		p.Cmds = append(p.Cmds, Command{Name: segment[0], Args: args})
+	}
+	return p, nil
+}
+
+// parseOperands parses the words into operands. Since the template is split
+// into words by whitespace, the words of a quoted string are joined back with
+// a single space.
+func parseOperands(words []string) ([]Operand, error) {
+	var operands []Operand
+	for i := 0; i < len(words); i++ {
+		s := words[i]
+		if strings.HasPrefix(s, `"`) {
+			for !isClosedQuote(s) && i+1 < len(words) {
+				i++
+				s += " " + words[i]
+			}
+		}
+		o, err := parseOperand(s)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, o)
+	}
+	return operands, nil
+}
+
+// parseOperand parses a variable, a quoted string, a boolean or a number.
+func parseOperand(s string) (Operand, error) {
+	if isVariable(s) {
+		return Operand{Variable: s}, nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return Operand{}, errors.Errorf("invalid string %s", s)
+		}
+		return Operand{Value: v}, nil
+	}
+	if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false") {
+		return Operand{Value: b}, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return Operand{Value: n}, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return Operand{Value: f}, nil
+	}
+	return Operand{}, errors.Errorf("unexpected %q, expected a variable, a string or a number", s)
+}
+
+// isClosedQuote checks if s, starting with a double quote, ends with an
+// unescaped double quote.
+func isClosedQuote(s string) bool {
+	if len(s) < 2 || !strings.HasSuffix(s, `"`) {
+		return false
+	}
+	var backslashes int
+	for i := len(s) - 2; i >= 0 && s[i] == '\\'; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 0
+}
@@ -0,0 +1,214 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/pkg/errors"
+)
+
+func TestParsePipeline(t *testing.T) {
+	cases := []struct {
+		desc     string
+		input    string
+		isError  bool
+		expected *Pipeline
+	}{
+		{
+			desc:  "Single function",
+			input: `{{ .Name | upper }}`,
+			expected: &Pipeline{
+				Operand: Operand{Variable: ".Name"},
+				Cmds:    []Command{{Name: "upper"}},
+			},
+		},
+		{
+			desc:  "Function with args",
+			input: `{{ .Cols | join ", " | pad 2 .Width 1.5 true }}`,
+			expected: &Pipeline{
+				Operand: Operand{Variable: ".Cols"},
+				Cmds: []Command{
+					{Name: "join", Args: []Operand{{Value: ", "}}},
+					{Name: "pad", Args: []Operand{{Value: 2}, {Variable: ".Width"}, {Value: 1.5}, {Value: true}}},
+				},
+			},
+		},
+		{
+			desc:  "Constant operand with quoted spaces",
+			input: `{{ "a \" b" | lower }}`,
+			expected: &Pipeline{
+				Operand: Operand{Value: `a " b`},
+				Cmds:    []Command{{Name: "lower"}},
+			},
+		},
+		{
+			desc:    "Missing function name",
+			input:   `{{ .Name | | upper }}`,
+			isError: true,
+		},
+		{
+			desc:    "Value instead of function name",
+			input:   `{{ .Name | .Other }}`,
+			isError: true,
+		},
+		{
+			desc:    "More than one operand",
+			input:   `{{ .Name .Other | upper }}`,
+			isError: true,
+		},
+		{
+			desc:    "Bare word argument",
+			input:   `{{ .Name | join sep }}`,
+			isError: true,
+		},
+		{
+			desc:    "Unterminated string",
+			input:   `{{ .Name | join ", }}`,
+			isError: true,
+		},
+		{
+			desc:    "Nested expression",
+			input:   `{{ .Name | join {{ .Sep }} }}`,
+			isError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expr := tt.chunks[0].(*TokenTree)
+			if ok, err := isPipeline(expr); err != nil || !ok {
+				if !c.isError {
+					t.Fatalf("Expected a pipeline, got error: %v", err)
+				}
+				return
+			}
+			output, err := parsePipeline(expr)
+			if err != nil {
+				if !c.isError {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			} else if c.isError {
+				t.Fatalf("Expected error but got nil error")
+			}
+			if diff := deep.Equal(c.expected, output); diff != nil {
+				t.Errorf("Wrong result: %v", diff)
+			}
+		})
+	}
+}
+
+func TestPipeline_Evaluate(t *testing.T) {
+	cases := []struct {
+		desc     string
+		input    string
+		isError  bool
+		expected string
+	}{
+		{
+			desc:     "Built-in string functions",
+			input:    `{{ .Name | trim | upper }}`,
+			expected: "JOHN",
+		},
+		{
+			desc:     "Join",
+			input:    `{{ .Cols | join ", " }}`,
+			expected: "id, name, price",
+		},
+		{
+			desc:     "Join ints",
+			input:    `{{ .IDs | join "," }}`,
+			expected: "1,2,3",
+		},
+		{
+			desc:     "Like escapes wildcards",
+			input:    `{{ .Term | like }}`,
+			expected: `'%50\%\_off''s%' ESCAPE '\'`,
+		},
+		{
+			desc:     "Custom function with variable and number args",
+			input:    `{{ .Name | repeat .Times }}`,
+			expected: " John  John ",
+		},
+		{
+			desc:     "Custom function converting number args",
+			input:    `{{ 2 | scale 1.5 }}`,
+			expected: "3",
+		},
+		{
+			desc:     "Custom variadic function",
+			input:    `{{ "c" | concat "a" "b" }}`,
+			expected: "abc",
+		},
+		{
+			desc:    "Function returning an error",
+			input:   `{{ .Name | fail }}`,
+			isError: true,
+		},
+		{
+			desc:    "Undefined function",
+			input:   `{{ .Name | nope }}`,
+			isError: true,
+		},
+		{
+			desc:    "Wrong number of args",
+			input:   `{{ .Name | upper "x" }}`,
+			isError: true,
+		},
+		{
+			desc:    "Wrong type of arg",
+			input:   `{{ .Times | upper }}`,
+			isError: true,
+		},
+		{
+			desc:    "Join a non-slice",
+			input:   `{{ .Name | join "," }}`,
+			isError: true,
+		},
+	}
+
+	env := &Env{
+		Vars: map[string]interface{}{
+			".Name":  " John ",
+			".Cols":  []string{"id", "name", "price"},
+			".IDs":   []int{1, 2, 3},
+			".Term":  "50%_off's",
+			".Times": 2,
+		},
+		Funcs: FuncMap{
+			"repeat": func(n int, s string) string { return strings.Repeat(s, n) },
+			"scale":  func(factor float64, n float64) float64 { return factor * n },
+			"concat": func(ss ...string) string { return strings.Join(ss, "") },
+			"fail":   func(s string) (string, error) { return "", errors.New("failed") },
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			st, err := tt.Parse()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			output, err := st.Evaluate(env)
+			if err != nil {
+				if !c.isError {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			} else if c.isError {
+				t.Fatalf("Expected error but got nil error")
+			}
+			if output != c.expected {
+				t.Fatalf("Expected %v but got %v", c.expected, output)
+			}
+		})
+	}
+}
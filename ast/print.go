@@ -0,0 +1,120 @@
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Print returns the template source of node, which parses back to the same
+// tree. The words are separated by single spaces, so the whitespace of the
+// original template isn't kept.
+//
+// A SyntaxTree is printed as a template, or as a clause if it's the clause of
+// a block, and a SyntaxTree nested in another one as an expression, enclosed
+// in {{ and }}.
+func Print(node LanguageNode) string {
+	p := &printer{}
+	if t, ok := node.(*SyntaxTree); ok {
+		p.tree(t)
+	} else {
+		p.node(node)
+	}
+	return strings.Join(p.words, " ")
+}
+
+type printer struct {
+	words []string
+}
+
+func (p *printer) print(words ...string) {
+	for _, w := range words {
+		if w != "" {
+			p.words = append(p.words, w)
+		}
+	}
+}
+
+// tree prints the nodes of the tree, without enclosing them in {{ and }}.
+func (p *printer) tree(t *SyntaxTree) {
+	if t == nil {
+		return
+	}
+	for _, n := range t.Nodes {
+		p.node(n)
+	}
+}
+
+func (p *printer) node(node LanguageNode) {
+	switch n := node.(type) {
+	case *SyntaxTree:
+		if n == nil || len(n.Nodes) == 0 {
+			return
+		}
+		if isBlockExpr(n) {
+			// The block prints its own delimiters.
+			p.tree(n)
+			return
+		}
+		p.print(keywordLanguageStart)
+		p.tree(n)
+		p.print(keywordLanguageEnd)
+	case *Literal:
+		p.print(n.String())
+	case *IfBlock:
+		p.print(keywordLanguageStart, keywordIf, n.predicateString())
+		if n.PredicateDefault != "" {
+			p.print(keywordDefault, n.PredicateDefault)
+		}
+		p.print(keywordThen)
+		p.tree(n.Then)
+		if n.Else != nil {
+			p.print(keywordElse)
+			p.tree(n.Else)
+		}
+		p.print(keywordLanguageEnd)
+	case *DefaultBlock:
+		p.print(keywordLanguageStart, keywordDefault, n.Name)
+		p.print(n.Value...)
+		p.print(keywordLanguageEnd)
+	case *IdentBlock:
+		p.print(keywordLanguageStart, keywordIdent, n.Name, keywordLanguageEnd)
+	case *RawBlock:
+		p.print(keywordLanguageStart, keywordRaw, n.Name, keywordLanguageEnd)
+	case *AllowlistBlock:
+		p.print(keywordLanguageStart, n.Keyword, n.Name)
+		for _, v := range n.Values {
+			p.print(strconv.Quote(v))
+		}
+		p.print(keywordLanguageEnd)
+	case *ParamsBlock:
+		p.print(keywordLanguageStart, keywordParams)
+		for _, param := range n.Params {
+			p.print(param.String())
+		}
+		p.print(keywordLanguageEnd)
+	case *DefineBlock:
+		p.print(keywordLanguageStart, keywordDefine, n.Name)
+		p.tree(n.Body)
+		p.print(keywordLanguageEnd)
+	case *IncludeBlock:
+		p.print(keywordLanguageStart, keywordInclude, n.Name, keywordLanguageEnd)
+	case *Pipeline:
+		p.print(keywordLanguageStart, n.String(), keywordLanguageEnd)
+	case fmt.Stringer:
+		p.print(n.String())
+	}
+}
+
+// isBlockExpr checks if the tree is an expression parsed into a block, as
+// opposed to a plain expression of words.
+func isBlockExpr(t *SyntaxTree) bool {
+	if len(t.Nodes) != 1 {
+		return false
+	}
+	switch t.Nodes[0].(type) {
+	case *Literal, *SyntaxTree:
+		return false
+	}
+	return true
+}
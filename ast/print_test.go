@@ -0,0 +1,88 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+// mustParse parses the template into a SyntaxTree, failing the test if it's
+// not valid.
+func mustParse(t *testing.T, input string) *SyntaxTree {
+	t.Helper()
+	tt, err := BuildTokenTree(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	node, err := tt.Parse()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return node.(*SyntaxTree)
+}
+
+func TestPrint(t *testing.T) {
+	cases := []struct {
+		desc     string
+		input    string
+		expected string
+	}{
+		{
+			desc: "Words and a plain expression",
+			input: `SELECT *
+				FROM products {{ WHERE   true }}`,
+			expected: `SELECT * FROM products {{ WHERE true }}`,
+		},
+		{
+			desc:     "If block",
+			input:    `SELECT * FROM products {{ [if] .InStock [default] true [then] WHERE stock > 0 [else] WHERE true }}`,
+			expected: `SELECT * FROM products {{ [if] .InStock [default] true [then] WHERE stock > 0 [else] WHERE true }}`,
+		},
+		{
+			desc:     "Nested blocks",
+			input:    `{{ [if] .A [then] a {{ [if] .B [then] b {{ c }} }} }}`,
+			expected: `{{ [if] .A [then] a {{ [if] .B [then] b {{ c }} }} }}`,
+		},
+		{
+			desc:     "Pipelines",
+			input:    `{{ [if] .Role | is "admin user" [then] {{ .Name | like | quote }} }}`,
+			expected: `{{ [if] .Role | is "admin user" [then] {{ .Name | like | quote }} }}`,
+		},
+		{
+			desc: "Other blocks",
+			input: `{{ [params] .Category:string! .Limit:int=50 .Any }}
+				{{ [define] active deleted_at IS NULL }}
+				SELECT {{ [ident] .Column }} FROM t WHERE {{ [include] active }} AND {{ [raw] .Filter }}
+				ORDER BY {{ [oneof] .Sort "name" "created at" }} {{ [dir] .Dir }}
+				LIMIT {{ [default] .Limit 50 }}`,
+			expected: `{{ [params] .Category:string! .Limit:int=50 .Any }} ` +
+				`{{ [define] active deleted_at IS NULL }} ` +
+				`SELECT {{ [ident] .Column }} FROM t WHERE {{ [include] active }} AND {{ [raw] .Filter }} ` +
+				`ORDER BY {{ [oneof] .Sort "name" "created at" }} {{ [dir] .Dir }} ` +
+				`LIMIT {{ [default] .Limit 50 }}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			st := mustParse(t, c.input)
+			result := Print(st)
+			if result != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, result)
+			}
+			if diff := deep.Equal(st, mustParse(t, result)); diff != nil {
+				t.Errorf("Printed template parses to a different tree: %v", diff)
+			}
+		})
+	}
+}
+
+func TestPrint_Node(t *testing.T) {
+	ib := &IfBlock{
+		PredicateExpr: []string{".InStock"},
+		Then:          &SyntaxTree{Nodes: []LanguageNode{&Literal{"stock"}, &Literal{">"}, &Literal{"0"}}},
+	}
+	expected := `{{ [if] .InStock [then] stock > 0 }}`
+	if result := Print(ib); result != expected {
+		t.Errorf("Expected %s, got %s", expected, result)
+	}
+}
@@ -0,0 +1,69 @@
+package ast
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const keywordRaw = "[raw]"
+
+// Raw is a trusted SQL fragment, such as a subquery built elsewhere, which is
+// inlined verbatim even when the values of the variables are bound.
+type Raw string
+
+// RawBlock represents a parsed syntax state of a [raw] block, which evaluates
+// to the value of the variable verbatim, even when values are bound.
+type RawBlock struct {
+	Name string
+}
+
+// Evaluate returns the value of the variable verbatim.
+func (rb *RawBlock) Evaluate(env *Env) (string, error) {
+	if rb == nil {
+		return "", nil
+	}
+	v, ok, err := env.lookup(rb.Name, "")
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return rb.Name, nil
+	}
+	return fmt.Sprintf("%v", v), nil
+}
+
+// Variables returns the variable of this RawBlock.
+func (rb *RawBlock) Variables() []Variable {
+	if rb == nil {
+		return nil
+	}
+	return []Variable{{Name: rb.Name, Value: true}}
+}
+
+// isRawBlock checks if the TokenTree is analyzed to a raw block.
+func isRawBlock(tt *TokenTree) (bool, error) {
+	if len(tt.chunks) == 0 {
+		return false, errors.New("expression with empty chunks")
+	}
+
+	if maybeRaw, ok := tt.chunks[0].(*Literal); !ok || maybeRaw.String() != keywordRaw {
+		return false, nil
+	}
+
+	if len(tt.chunks) != 2 {
+		return false, errors.New("[raw] must be followed by a single variable")
+	}
+	if name, ok := tt.chunks[1].(*Literal); !ok || !isVariable(name.String()) {
+		return false, errors.New("[raw] must be followed by a single variable")
+	}
+
+	return true, nil
+}
+
+// parseRawBlock parses the TokenTree and returns the parsed RawBlock. It
+// assumes the TokenTree is a valid raw block (make sure to call isRawBlock
+// first).
+func parseRawBlock(tt *TokenTree) *RawBlock {
+	return &RawBlock{Name: tt.chunks[1].(*Literal).String()}
+}
@@ -0,0 +1,91 @@
+package ast
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSyntaxTree_EvaluateBind(t *testing.T) {
+	cases := []struct {
+		desc         string
+		input        string
+		inputVars    map[string]interface{}
+		expected     string
+		expectedArgs []interface{}
+	}{
+		{
+			desc:         "Variables are bound",
+			input:        `WHERE category = .Category AND price < .Price`,
+			inputVars:    map[string]interface{}{".Category": "food", ".Price": 10},
+			expected:     "WHERE category = $1 AND price < $2",
+			expectedArgs: []interface{}{"food", 10},
+		},
+		{
+			desc:      "Raw values are inlined",
+			input:     `WHERE .Filter`,
+			inputVars: map[string]interface{}{".Filter": Raw("id IN (SELECT product_id FROM featured)")},
+			expected:  "WHERE id IN (SELECT product_id FROM featured)",
+		},
+		{
+			desc:      "Raw blocks are inlined",
+			input:     `WHERE {{ [raw] .Filter }}`,
+			inputVars: map[string]interface{}{".Filter": "deleted_at IS NULL"},
+			expected:  "WHERE deleted_at IS NULL",
+		},
+		{
+			desc:         "Default values are bound, but not the defaults",
+			input:        `LIMIT {{ [default] .Limit 50 }} OFFSET {{ [default] .Offset 0 }}`,
+			inputVars:    map[string]interface{}{".Limit": 10},
+			expected:     "LIMIT $1 OFFSET 0",
+			expectedArgs: []interface{}{10},
+		},
+		{
+			desc:         "Pipelines are bound",
+			input:        `WHERE name LIKE {{ .Name | like }}`,
+			inputVars:    map[string]interface{}{".Name": "50%"},
+			expected:     `WHERE name LIKE $1 ESCAPE '\'`,
+			expectedArgs: []interface{}{`%50\%%`},
+		},
+		{
+			desc:      "Predicates aren't bound",
+			input:     `SELECT * {{ [if] .All [then] FROM products }}`,
+			inputVars: map[string]interface{}{".All": true},
+			expected:  "SELECT * FROM products",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			st, err := tt.Parse()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var args []interface{}
+			result, err := st.Evaluate(&Env{
+				Vars: c.inputVars,
+				Bind: func(v interface{}) string {
+					args = append(args, v)
+					return "$" + strconv.Itoa(len(args))
+				},
+			})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if result != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, result)
+			}
+			if len(args) != len(c.expectedArgs) {
+				t.Fatalf("Expected args %v, got %v", c.expectedArgs, args)
+			}
+			for i := range args {
+				if args[i] != c.expectedArgs[i] {
+					t.Errorf("Expected args %v, got %v", c.expectedArgs, args)
+				}
+			}
+		})
+	}
+}
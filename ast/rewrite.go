@@ -0,0 +1,67 @@
+package ast
+
+// Rewrite returns a copy of the tree of node, with each node replaced by the
+// result of fn. The nodes are rewritten bottom-up, so fn is given a node whose
+// children have been rewritten already. Returning the node itself keeps it,
+// and returning nil removes it from its SyntaxTree. A node other than a
+// SyntaxTree which is replaced with a SyntaxTree, such as an [if] block
+// replaced with its [then] clause, is replaced with the nodes of the tree.
+//
+// The tree of node isn't modified: the SyntaxTrees, [if] and [define] blocks
+// on the way to a rewritten node are copied, and the other nodes are shared
+// with the original tree. Like Walk, Rewrite doesn't enter included partials.
+func Rewrite(node LanguageNode, fn func(LanguageNode) LanguageNode) LanguageNode {
+	switch n := node.(type) {
+	case *SyntaxTree:
+		if n != nil {
+			t := &SyntaxTree{Nodes: make([]LanguageNode, 0, len(n.Nodes))}
+			for _, child := range n.Nodes {
+				c := Rewrite(child, fn)
+				if sub, ok := c.(*SyntaxTree); ok {
+					if _, isTree := child.(*SyntaxTree); !isTree {
+						// A block replaced with a clause, such as its own
+						// [then] clause, takes the place of the block.
+						if sub != nil {
+							t.Nodes = append(t.Nodes, sub.Nodes...)
+						}
+						continue
+					}
+				}
+				if c != nil {
+					t.Nodes = append(t.Nodes, c)
+				}
+			}
+			node = t
+		}
+	case *IfBlock:
+		if n != nil {
+			ib := *n
+			ib.Then = rewriteClause(n.Then, fn)
+			ib.Else = rewriteClause(n.Else, fn)
+			node = &ib
+		}
+	case *DefineBlock:
+		if n != nil {
+			db := *n
+			db.Body = rewriteClause(n.Body, fn)
+			node = &db
+		}
+	}
+	return fn(node)
+}
+
+// rewriteClause rewrites the clause t of a block, which stays a SyntaxTree
+// even if fn replaces it with another kind of node, or removes it.
+func rewriteClause(t *SyntaxTree, fn func(LanguageNode) LanguageNode) *SyntaxTree {
+	if t == nil {
+		return nil
+	}
+	switch n := Rewrite(t, fn).(type) {
+	case nil:
+		return &SyntaxTree{}
+	case *SyntaxTree:
+		return n
+	default:
+		return &SyntaxTree{Nodes: []LanguageNode{n}}
+	}
+}
@@ -0,0 +1,35 @@
+package ast
+
+import (
+	"testing"
+)
+
+func TestRewrite(t *testing.T) {
+	input := `SELECT * FROM products WHERE true
+		{{ [if] .InStock [then] AND stock > 0 {{ [raw] .Filter }} }}
+		{{ [raw] .Extra }}`
+	st := mustParse(t, input)
+
+	// Replace [raw] blocks with plain variables, and the [if] block of
+	// .InStock with its [then] clause.
+	result := Rewrite(st, func(n LanguageNode) LanguageNode {
+		switch n := n.(type) {
+		case *RawBlock:
+			if n.Name == ".Extra" {
+				return nil
+			}
+			return &Literal{n.Name}
+		case *IfBlock:
+			return n.Then
+		}
+		return n
+	})
+
+	expected := `SELECT * FROM products WHERE true {{ AND stock > 0 {{ .Filter }} }}`
+	if s := Print(result); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	if s, original := Print(st), Print(mustParse(t, input)); s != original {
+		t.Errorf("Expected the original tree to be unchanged, got %s", s)
+	}
+}
@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"database/sql/driver"
 	"fmt"
 	"strings"
 
@@ -11,108 +12,215 @@ const (
 	keywordIf            = "[if]"
 	keywordThen          = "[then]"
 	keywordElse          = "[else]"
+	keywordDefault       = "[default]"
 	keywordLanguageStart = "{{"
 	keywordLanguageEnd   = "}}"
 )
 
-func isKeyword(s string) bool {
+// IsKeyword checks if s is a keyword of the template syntax, such as [if] or
+// {{.
+func IsKeyword(s string) bool {
 	return s == keywordIf ||
 		s == keywordThen ||
 		s == keywordElse ||
+		s == keywordDefault ||
+		s == keywordParams ||
+		s == keywordIdent ||
+		s == keywordRaw ||
+		s == keywordDefine ||
+		s == keywordInclude ||
+		s == keywordOneOf ||
+		s == keywordDir ||
 		s == keywordLanguageStart ||
 		s == keywordLanguageEnd
 }
 
-// literal represents a token of a literal string in the template.
-type literal struct {
-	s string
+// Literal represents a token of a literal string in the template.
+type Literal struct {
+	Text string
 }
 
 // String is a string representation of literal.
-func (l *literal) String() string {
+func (l *Literal) String() string {
 	if l == nil {
 		return ""
 	}
-	return l.s
+	return l.Text
 }
 
 // Parse converts the literal to the LanguageNode interface value.
-func (l *literal) Parse() (LanguageNode, error) {
+func (l *Literal) Parse() (LanguageNode, error) {
 	return l, nil
 }
 
-// SubstituteVars performs var substitution on this literal instance.
-func (l *literal) SubstituteVars(vars map[string]interface{}) error {
+// Evaluate returns the evaluated value of the literal, substituting it with
+// the value of the variable it references, if any.
+func (l *Literal) Evaluate(env *Env) (string, error) {
 	if l == nil {
-		return nil
+		return "", nil
 	}
-	v, ok := vars[string(l.s)]
-	if ok {
-		l.s = fmt.Sprintf("%v", v)
+	v, ok, err := env.lookup(l.Text, "")
+	if err != nil {
+		return "", err
 	}
-	return nil
+	if !ok {
+		return l.Text, nil
+	}
+	if _, given := env.get(l.Text); !given {
+		// A missing variable evaluates to the zero value, which removes it
+		// rather than binding it.
+		return fmt.Sprintf("%v", v), nil
+	}
+	return env.format(v)
 }
 
-// Evaluate returns the evaluated value of the literal.
-func (l *literal) Evaluate() string {
-	if l == nil {
-		return ""
+// Variables returns the variable the literal references, if any.
+func (l *Literal) Variables() []Variable {
+	if l == nil || !isVariable(l.Text) {
+		return nil
 	}
-	return l.s
+	return []Variable{{Name: l.Text, Value: true}}
 }
 
-// ifBlock represents a parsed syntax state of an [if] block.
-type ifBlock struct {
-	predicateExpr []string
-	then          *SyntaxTree
-	otherwise     *SyntaxTree
+// IfBlock represents a parsed syntax state of an [if] block.
+type IfBlock struct {
+	// PredicateExpr are the words of the predicate, such as .IncludeReviews.
+	PredicateExpr []string
+	// PredicateDefault is the value of the predicate if the variable is
+	// missing, or empty if there's no default.
+	PredicateDefault string
+	// PredicatePipe is the predicate if it's a pipeline, such as
+	// [if] .Role | isAdmin [then], in which case PredicateExpr is empty.
+	PredicatePipe *Pipeline
+	Then          *SyntaxTree
+	// Else is the [else] clause, or nil if there's none.
+	Else *SyntaxTree
 }
 
-// SubstituteVars performs var substitution on the predicate and expression of
-// this ifBlock instance.
-func (ib *ifBlock) SubstituteVars(vars map[string]interface{}) error {
+// Evaluate returns the evaluated value of this IfBlock's expression if
+// predicate evaluates to true, otherwise returns the evaluated value of the
+// [else] expression, or an empty string if there's none.
+func (ib *IfBlock) Evaluate(env *Env) (string, error) {
 	if ib == nil {
-		return nil
+		return "", nil
 	}
-	if len(ib.predicateExpr) == 0 {
-		return errors.New("predicate expression not found")
-	} else if len(ib.predicateExpr) > 1 {
-		return errors.New("multi-token expression predicate is not supported yet")
+	ok, err := ib.predicate(env)
+	if err != nil {
+		return "", err
 	}
-	v, ok := vars[ib.predicateExpr[0]]
 	if ok {
-		ib.predicateExpr = []string{fmt.Sprintf("%v", v)}
-	}
-	if boolExpr := strings.ToLower(ib.predicateExpr[0]); boolExpr != "true" && boolExpr != "false" {
-		return errors.New("predicate must be a boolean expression")
+		return ib.Then.Evaluate(env)
 	}
+	return ib.Else.Evaluate(env)
+}
 
-	if err := ib.then.SubstituteVars(vars); err != nil {
-		return err
+// predicate returns the evaluated value of the predicate, or the value
+// decided by the Predicate of the Env if it's set.
+func (ib *IfBlock) predicate(env *Env) (bool, error) {
+	if env != nil && env.Predicate != nil {
+		return env.Predicate(ib.predicateString()), nil
+	}
+	if ib.PredicatePipe != nil {
+		v, err := ib.PredicatePipe.value(env)
+		if err != nil {
+			return false, err
+		}
+		b, ok := v.(bool)
+		if !ok {
+			return false, errors.Errorf("predicate must be a boolean expression, got %T", v)
+		}
+		return b, nil
 	}
 
-	if ib.otherwise != nil {
-		if err := ib.otherwise.SubstituteVars(vars); err != nil {
-			return err
+	if len(ib.PredicateExpr) == 0 {
+		return false, errors.New("predicate expression not found")
+	} else if len(ib.PredicateExpr) > 1 {
+		return false, errors.New("multi-token expression predicate is not supported yet")
+	}
+	predicate := ib.PredicateExpr[0]
+	var (
+		v   interface{}
+		ok  bool
+		err error
+	)
+	if _, given := env.get(predicate); !given && ib.PredicateDefault != "" {
+		v, ok = ib.PredicateDefault, true
+	} else if v, ok, err = env.lookup(predicate, false); err != nil {
+		return false, err
+	}
+	if _, isValuer := v.(driver.Valuer); isValuer {
+		// A NULL is false, and any other value is true unless it's a boolean.
+		if v, err = driverValue(v); err != nil {
+			return false, err
+		}
+		if v == nil {
+			return false, nil
+		}
+		if _, isBool := v.(bool); !isBool {
+			return true, nil
 		}
 	}
 
-	return nil
+	if ok && v == nil {
+		// Such as the value of an untyped parameter which isn't given.
+		return false, nil
+	}
+
+	boolExpr := predicate
+	if ok {
+		boolExpr = fmt.Sprintf("%v", v)
+	}
+
+	switch strings.ToLower(boolExpr) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if ok {
+		return false, errors.Errorf("predicate %s must be a boolean expression, got %q", predicate, boolExpr)
+	}
+	return false, errors.Errorf("predicate %s must be a boolean expression", predicate)
 }
 
-// Evaluate returns the evaluated value of this ifBlock's expression if
-// predicate evaluates to true, otherwise returns an empty string
-func (ib *ifBlock) Evaluate() string {
+// Variables returns the variables referenced in the predicate and in both the
+// [then] and [else] expressions of this IfBlock.
+func (ib *IfBlock) Variables() []Variable {
 	if ib == nil {
-		return ""
+		return nil
+	}
+	vars := ib.predicateVariables()
+	vars = append(vars, ib.Then.Variables()...)
+	return append(vars, ib.Else.Variables()...)
+}
+
+// predicateVariables returns the variables referenced in the predicate.
+func (ib *IfBlock) predicateVariables() []Variable {
+	var vars []Variable
+	for _, expr := range ib.PredicateExpr {
+		if isVariable(expr) {
+			vars = append(vars, Variable{
+				Name:       expr,
+				Predicate:  true,
+				HasDefault: ib.PredicateDefault != "",
+			})
+		}
 	}
-	if strings.ToLower(ib.predicateExpr[0]) == "true" {
-		return ib.then.Evaluate()
-	} else if ib.otherwise != nil {
-		return ib.otherwise.Evaluate()
+	for _, v := range ib.PredicatePipe.Variables() {
+		v.Predicate = true
+		v.Value = false
+		vars = append(vars, v)
 	}
+	return vars
+}
 
-	return ""
+// predicateString returns the predicate as written in the template, without
+// its default value.
+func (ib *IfBlock) predicateString() string {
+	if ib.PredicatePipe != nil {
+		return ib.PredicatePipe.String()
+	}
+	return strings.Join(ib.PredicateExpr, " ")
 }
 
 // isIfBlock checks if the TokenTree is analyzed to an if block.
@@ -121,7 +229,7 @@ func isIfBlock(tt *TokenTree) (bool, error) {
 		return false, errors.New("expression with empty chunks")
 	}
 
-	if maybeIf, ok := tt.chunks[0].(*literal); !ok || maybeIf.String() != keywordIf {
+	if maybeIf, ok := tt.chunks[0].(*Literal); !ok || maybeIf.String() != keywordIf {
 		return false, nil
 	}
 
@@ -129,9 +237,9 @@ func isIfBlock(tt *TokenTree) (bool, error) {
 		thenIndex int
 	)
 	for i, chunk := range tt.chunks {
-		literalChunk, isLiteral := chunk.(*literal)
+		literalChunk, isLiteral := chunk.(*Literal)
 		if i == 1 {
-			if !isLiteral || isKeyword(string(literalChunk.String())) {
+			if !isLiteral || IsKeyword(string(literalChunk.String())) {
 				return false, errors.New("[if] must be followed by a predicate")
 			}
 		}
@@ -146,18 +254,20 @@ func isIfBlock(tt *TokenTree) (bool, error) {
 	return true, nil
 }
 
-// parseIfBlock parses the TokenTree and returns the parsed ifBlock.
+// parseIfBlock parses the TokenTree and returns the parsed IfBlock.
 // It assumes the TokenTree is a valid if block (make sure to call isIfBlock first).
 // If it's not, returns an error.
-func parseIfBlock(tt *TokenTree) (*ifBlock, error) {
-	ib := &ifBlock{}
+func parseIfBlock(tt *TokenTree) (*IfBlock, error) {
+	ib := &IfBlock{}
 	var (
 		isIf   bool = true
 		isThen bool
 		isElse bool
 	)
+	var skipNext bool
 	for i, chunk := range tt.chunks {
-		if i == 0 {
+		if i == 0 || skipNext {
+			skipNext = false
 			continue
 		}
 
@@ -170,32 +280,155 @@ func parseIfBlock(tt *TokenTree) (*ifBlock, error) {
 			}
 
 			if isThen {
-				ib.then.children = append(ib.then.children, node)
+				ib.Then.Nodes = append(ib.Then.Nodes, node)
 			} else if isElse {
-				ib.otherwise.children = append(ib.otherwise.children, node)
+				ib.Else.Nodes = append(ib.Else.Nodes, node)
 			}
 		}
 
-		literalChunk, isLiteral := chunk.(*literal)
+		literalChunk, isLiteral := chunk.(*Literal)
 		if isLiteral {
 			if literalChunk.String() == keywordThen {
+				if ib.Then != nil {
+					return nil, errors.New("[if] block has more than one [then]")
+				}
 				isIf = false
 				isThen = true
-				ib.then = &SyntaxTree{}
+				ib.Then = &SyntaxTree{}
 			} else if literalChunk.String() == keywordElse {
+				if ib.Then == nil {
+					return nil, errors.New("[else] must follow the [then] clause")
+				}
+				if ib.Else != nil {
+					return nil, errors.New("[if] block has more than one [else]")
+				}
 				isIf = false
 				isThen = false
 				isElse = true
-				ib.otherwise = &SyntaxTree{}
+				ib.Else = &SyntaxTree{}
+			} else if isIf && literalChunk.String() == keywordDefault {
+				if len(ib.PredicateExpr) != 1 || i+1 >= len(tt.chunks) {
+					return nil, errors.New("[default] in a predicate must be between a variable and its default value")
+				}
+				defaultChunk, ok := tt.chunks[i+1].(*Literal)
+				if !ok || IsKeyword(defaultChunk.String()) {
+					return nil, errors.New("[default] in a predicate must be followed by a default value")
+				}
+				ib.PredicateDefault = defaultChunk.String()
+				skipNext = true
 			} else if isIf {
-				ib.predicateExpr = append(ib.predicateExpr, literalChunk.String())
+				ib.PredicateExpr = append(ib.PredicateExpr, literalChunk.String())
 			} else if isThen {
-				ib.then.children = append(ib.then.children, literalChunk)
+				ib.Then.Nodes = append(ib.Then.Nodes, literalChunk)
 			} else if isElse {
-				ib.otherwise.children = append(ib.otherwise.children, literalChunk)
+				ib.Else.Nodes = append(ib.Else.Nodes, literalChunk)
 			}
 		}
 	}
 
+	if err := parsePredicatePipeline(ib); err != nil {
+		return nil, err
+	}
+
 	return ib, nil
 }
+
+// parsePredicatePipeline parses the predicate of the IfBlock into a pipeline,
+// if it is one.
+func parsePredicatePipeline(ib *IfBlock) error {
+	var isPipe bool
+	for _, expr := range ib.PredicateExpr {
+		if expr == pipeSeparator {
+			isPipe = true
+		}
+	}
+	if !isPipe {
+		return nil
+	}
+	if ib.PredicateDefault != "" {
+		return errors.New("[default] can't be used in a pipeline predicate")
+	}
+
+	pt := &TokenTree{chunks: make([]chunk, len(ib.PredicateExpr))}
+	for i, expr := range ib.PredicateExpr {
+		pt.chunks[i] = &Literal{expr}
+	}
+	p, err := parsePipeline(pt)
+	if err != nil {
+		return errors.Wrap(err, "parsing a pipeline predicate")
+	}
+	ib.PredicateExpr = nil
+	ib.PredicatePipe = p
+	return nil
+}
+
+// DefaultBlock represents a parsed syntax state of a [default] block, which
+// evaluates to the value of the variable, or to the default value if the
+// variable is missing or has the zero value.
+type DefaultBlock struct {
+	Name string
+	// Value are the words of the default value.
+	Value []string
+}
+
+// Evaluate returns the value of the variable, or the default value if the
+// variable is missing or has the zero value.
+func (db *DefaultBlock) Evaluate(env *Env) (string, error) {
+	if db == nil {
+		return "", nil
+	}
+	v, ok := env.get(db.Name)
+	if !ok || isZero(v) {
+		return strings.Join(db.Value, " "), nil
+	}
+	return env.format(v)
+}
+
+// Variables returns the variable of this DefaultBlock.
+func (db *DefaultBlock) Variables() []Variable {
+	if db == nil {
+		return nil
+	}
+	return []Variable{{Name: db.Name, HasDefault: true, Value: true}}
+}
+
+// isDefaultBlock checks if the TokenTree is analyzed to a default block.
+func isDefaultBlock(tt *TokenTree) (bool, error) {
+	if len(tt.chunks) == 0 {
+		return false, errors.New("expression with empty chunks")
+	}
+
+	if maybeDefault, ok := tt.chunks[0].(*Literal); !ok || maybeDefault.String() != keywordDefault {
+		return false, nil
+	}
+
+	if len(tt.chunks) < 2 {
+		return false, errors.New("[default] must be followed by a variable")
+	}
+	if name, ok := tt.chunks[1].(*Literal); !ok || !isVariable(name.String()) {
+		return false, errors.New("[default] must be followed by a variable")
+	}
+	if len(tt.chunks) < 3 {
+		return false, errors.New("[default] must be followed by a default value after the variable")
+	}
+	for _, chunk := range tt.chunks[2:] {
+		if l, ok := chunk.(*Literal); !ok || IsKeyword(l.String()) {
+			return false, errors.New("default value must consist of plain words")
+		}
+	}
+
+	return true, nil
+}
+
+// parseDefaultBlock parses the TokenTree and returns the parsed DefaultBlock.
+// It assumes the TokenTree is a valid default block (make sure to call
+// isDefaultBlock first).
+func parseDefaultBlock(tt *TokenTree) *DefaultBlock {
+	db := &DefaultBlock{
+		Name: tt.chunks[1].(*Literal).String(),
+	}
+	for _, chunk := range tt.chunks[2:] {
+		db.Value = append(db.Value, chunk.(*Literal).String())
+	}
+	return db
+}
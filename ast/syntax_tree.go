@@ -6,36 +6,63 @@ import (
 
 // LanguageNode represents a node in the AST.
 type LanguageNode interface {
-	SubstituteVars(map[string]interface{}) error
-	Evaluate() string
+	Evaluate(*Env) (string, error)
+	Variables() []Variable
+}
+
+// Variable is a reference to a variable in the template.
+type Variable struct {
+	Name string
+	// Predicate is true if the variable is referenced as an [if] predicate.
+	Predicate bool
+	// HasDefault is true if the reference provides a default value for when
+	// the variable is missing.
+	HasDefault bool
+	// Piped is true if the variable is an operand of a pipeline, so its value
+	// is passed to a function.
+	Piped bool
+	// Value is true if the value of the variable, or of a pipeline it's an
+	// operand of, is substituted in the query.
+	Value bool
 }
 
 // SyntaxTree is a concrete implementation of the AST.
 type SyntaxTree struct {
-	children []LanguageNode
+	// Nodes are the words and expressions of the tree, in order. Each
+	// expression is parsed into a SyntaxTree of its own, which holds either
+	// the block of the expression, or its words if it's a plain expression.
+	Nodes []LanguageNode
 }
 
-// SubstituteVars performs recursive vars substitution on the SyntaxTree.
-func (t *SyntaxTree) SubstituteVars(vars map[string]interface{}) error {
+// Evaluate returns the recursively evaluated SyntaxTree, using the variables
+// and settings of env.
+// The tree itself is not modified, so it can be evaluated many times.
+func (t *SyntaxTree) Evaluate(env *Env) (string, error) {
 	if t == nil {
-		return nil
+		return "", nil
 	}
-	for _, node := range t.children {
-		if err := node.SubstituteVars(vars); err != nil {
-			return err
+	ns := make([]string, 0, len(t.Nodes))
+	for _, node := range t.Nodes {
+		n, err := node.Evaluate(env)
+		if err != nil {
+			return "", err
 		}
-	}
-	return nil
-}
-
-// Evaluate returns the recursively evaluated SyntaxTree.
-func (t *SyntaxTree) Evaluate() string {
-	ns := make([]string, 0, len(t.children))
-	for _, node := range t.children {
-		n := node.Evaluate()
 		if n != "" {
 			ns = append(ns, n)
 		}
 	}
-	return strings.Join(ns, " ")
+	return strings.Join(ns, " "), nil
+}
+
+// Variables returns the variables referenced in the SyntaxTree in the order
+// they appear, including the ones in branches that wouldn't be evaluated.
+func (t *SyntaxTree) Variables() []Variable {
+	if t == nil {
+		return nil
+	}
+	var vars []Variable
+	for _, node := range t.Nodes {
+		vars = append(vars, node.Variables()...)
+	}
+	return vars
 }
@@ -6,51 +6,51 @@ import (
 	"github.com/go-test/deep"
 )
 
-func TestSyntaxTree_SubstituteVars(t *testing.T) {
+func TestSyntaxTree_EvaluateVars(t *testing.T) {
 	cases := []struct {
-		desc               string
-		inputSyntaxTree    *SyntaxTree
-		inputVars          map[string]interface{}
-		isError            bool
-		expectedSyntaxTree *SyntaxTree
+		desc            string
+		inputSyntaxTree *SyntaxTree
+		inputVars       map[string]interface{}
+		isError         bool
+		expected        string
 	}{
 		{
-			desc:               "Input syntax tree is nil",
-			inputSyntaxTree:    nil,
-			isError:            false,
-			expectedSyntaxTree: nil,
+			desc:            "Input syntax tree is nil",
+			inputSyntaxTree: nil,
+			isError:         false,
+			expected:        "",
 		},
 		{
 			desc: "Well formatted nested syntax tree",
 			inputSyntaxTree: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"ABC"},
-					&ifBlock{
-						predicateExpr: []string{"GHI"},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"JKL"},
+				Nodes: []LanguageNode{
+					&Literal{"ABC"},
+					&IfBlock{
+						PredicateExpr: []string{"GHI"},
+						Then: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"JKL"},
 							},
 						},
 					},
-					&literal{"DEF"},
+					&Literal{"DEF"},
 					&SyntaxTree{
-						children: []LanguageNode{
-							&literal{"GHI"},
-							&ifBlock{
-								predicateExpr: []string{"false"},
-								then: &SyntaxTree{
-									children: []LanguageNode{
-										&literal{"JKL"},
+						Nodes: []LanguageNode{
+							&Literal{"GHI"},
+							&IfBlock{
+								PredicateExpr: []string{"false"},
+								Then: &SyntaxTree{
+									Nodes: []LanguageNode{
+										&Literal{"JKL"},
 									},
 								},
 							},
 						},
 					},
-					&literal{"GHI"},
+					&Literal{"GHI"},
 					&SyntaxTree{
-						children: []LanguageNode{
-							&literal{"ABC"},
+						Nodes: []LanguageNode{
+							&Literal{"ABC"},
 						},
 					},
 				},
@@ -59,51 +59,19 @@ func TestSyntaxTree_SubstituteVars(t *testing.T) {
 				"GHI": true,
 				"ABC": "Hello",
 			},
-			isError: false,
-			expectedSyntaxTree: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"Hello"},
-					&ifBlock{
-						predicateExpr: []string{"true"},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"JKL"},
-							},
-						},
-					},
-					&literal{"DEF"},
-					&SyntaxTree{
-						children: []LanguageNode{
-							&literal{"true"},
-							&ifBlock{
-								predicateExpr: []string{"false"},
-								then: &SyntaxTree{
-									children: []LanguageNode{
-										&literal{"JKL"},
-									},
-								},
-							},
-						},
-					},
-					&literal{"true"},
-					&SyntaxTree{
-						children: []LanguageNode{
-							&literal{"Hello"},
-						},
-					},
-				},
-			},
+			isError:  false,
+			expected: "Hello JKL DEF true true Hello",
 		},
 		{
 			desc: "Predicate expression has more than one tokens",
 			inputSyntaxTree: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"ABC"},
-					&ifBlock{
-						predicateExpr: []string{"GHI", "JKL"},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"JKL"},
+				Nodes: []LanguageNode{
+					&Literal{"ABC"},
+					&IfBlock{
+						PredicateExpr: []string{"GHI", "JKL"},
+						Then: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"JKL"},
 							},
 						},
 					},
@@ -118,13 +86,13 @@ func TestSyntaxTree_SubstituteVars(t *testing.T) {
 		{
 			desc: "Predicate expression has no tokens",
 			inputSyntaxTree: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"ABC"},
-					&ifBlock{
-						predicateExpr: []string{},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"JKL"},
+				Nodes: []LanguageNode{
+					&Literal{"ABC"},
+					&IfBlock{
+						PredicateExpr: []string{},
+						Then: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"JKL"},
 							},
 						},
 					},
@@ -139,13 +107,13 @@ func TestSyntaxTree_SubstituteVars(t *testing.T) {
 		{
 			desc: "Predicate expression evaluates to non-boolean",
 			inputSyntaxTree: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"ABC"},
-					&ifBlock{
-						predicateExpr: []string{"GHI"},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"JKL"},
+				Nodes: []LanguageNode{
+					&Literal{"ABC"},
+					&IfBlock{
+						PredicateExpr: []string{"GHI"},
+						Then: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"JKL"},
 							},
 						},
 					},
@@ -161,7 +129,7 @@ func TestSyntaxTree_SubstituteVars(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.desc, func(t *testing.T) {
-			err := c.inputSyntaxTree.SubstituteVars(c.inputVars)
+			output, err := c.inputSyntaxTree.Evaluate(&Env{Vars: c.inputVars})
 			if err != nil {
 				if !c.isError {
 					t.Fatalf("Unexpected error: %v, got: %v", c.isError, err)
@@ -170,8 +138,68 @@ func TestSyntaxTree_SubstituteVars(t *testing.T) {
 			} else if c.isError {
 				t.Fatalf("Expected error but got nil error")
 			}
-			if diff := deep.Equal(c.inputSyntaxTree, c.expectedSyntaxTree); diff != nil {
-				t.Fatalf("Wrong result: %v", diff)
+			if output != c.expected {
+				t.Fatalf("Expected %v but got %v", c.expected, output)
+			}
+		})
+	}
+}
+
+func TestSyntaxTree_EvaluateMissingKey(t *testing.T) {
+	inputSyntaxTree := &SyntaxTree{
+		Nodes: []LanguageNode{
+			&Literal{"ABC"},
+			&IfBlock{
+				PredicateExpr: []string{".Missing"},
+				Then: &SyntaxTree{
+					Nodes: []LanguageNode{
+						&Literal{"JKL"},
+					},
+				},
+			},
+			&Literal{".Missing"},
+			&Literal{".Given"},
+		},
+	}
+	cases := []struct {
+		desc       string
+		missingKey MissingKey
+		isError    bool
+		expected   string
+	}{
+		{
+			desc:       "Missing variable is an error",
+			missingKey: MissingKeyError,
+			isError:    true,
+		},
+		{
+			desc:       "Missing variable is the zero value",
+			missingKey: MissingKeyZero,
+			expected:   "ABC DEF",
+		},
+		{
+			desc:       "Missing variable is left as-is",
+			missingKey: MissingKeyLeave,
+			isError:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			output, err := inputSyntaxTree.Evaluate(&Env{
+				Vars:       map[string]interface{}{".Given": "DEF"},
+				MissingKey: c.missingKey,
+			})
+			if err != nil {
+				if !c.isError {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			} else if c.isError {
+				t.Fatalf("Expected error but got nil error")
+			}
+			if output != c.expected {
+				t.Fatalf("Expected %v but got %v", c.expected, output)
 			}
 		})
 	}
@@ -186,26 +214,26 @@ func TestSyntaxTree_Evaluate(t *testing.T) {
 		{
 			desc: "Evaluate a nested syntax tree",
 			inputSyntaxTree: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"ABC"},
-					&ifBlock{
-						predicateExpr: []string{"true"},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"JKL"},
+				Nodes: []LanguageNode{
+					&Literal{"ABC"},
+					&IfBlock{
+						PredicateExpr: []string{"true"},
+						Then: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"JKL"},
 							},
 						},
 					},
-					&literal{"GHI"},
-					&ifBlock{
-						predicateExpr: []string{"false"},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"DEF"},
+					&Literal{"GHI"},
+					&IfBlock{
+						PredicateExpr: []string{"false"},
+						Then: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"DEF"},
 							},
 						},
 					},
-					&literal{"MNO"},
+					&Literal{"MNO"},
 				},
 			},
 			expected: "ABC JKL GHI MNO",
@@ -213,36 +241,36 @@ func TestSyntaxTree_Evaluate(t *testing.T) {
 		{
 			desc: "Evaluate a nested syntax tree with if-else block",
 			inputSyntaxTree: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"ABC"},
-					&ifBlock{
-						predicateExpr: []string{"false"},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"JKL"},
+				Nodes: []LanguageNode{
+					&Literal{"ABC"},
+					&IfBlock{
+						PredicateExpr: []string{"false"},
+						Then: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"JKL"},
 							},
 						},
-						otherwise: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"MNO"},
+						Else: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"MNO"},
 							},
 						},
 					},
-					&literal{"GHI"},
-					&ifBlock{
-						predicateExpr: []string{"false"},
-						then: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"DEF"},
+					&Literal{"GHI"},
+					&IfBlock{
+						PredicateExpr: []string{"false"},
+						Then: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"DEF"},
 							},
 						},
-						otherwise: &SyntaxTree{
-							children: []LanguageNode{
-								&literal{"PQR"},
+						Else: &SyntaxTree{
+							Nodes: []LanguageNode{
+								&Literal{"PQR"},
 							},
 						},
 					},
-					&literal{"MNO"},
+					&Literal{"MNO"},
 				},
 			},
 			expected: "ABC MNO GHI PQR MNO",
@@ -251,10 +279,241 @@ func TestSyntaxTree_Evaluate(t *testing.T) {
 
 	for _, c := range cases {
 		t.Run(c.desc, func(t *testing.T) {
-			output := c.inputSyntaxTree.Evaluate()
+			output, err := c.inputSyntaxTree.Evaluate(nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output != c.expected {
+				t.Fatalf("Expected %v but got %v", c.expected, output)
+			}
+		})
+	}
+}
+
+func TestSyntaxTree_EvaluateDefault(t *testing.T) {
+	inputSyntaxTree := &SyntaxTree{
+		Nodes: []LanguageNode{
+			&Literal{"LIMIT"},
+			&DefaultBlock{
+				Name:  ".Limit",
+				Value: []string{"50"},
+			},
+			&IfBlock{
+				PredicateExpr:    []string{".Desc"},
+				PredicateDefault: "true",
+				Then: &SyntaxTree{
+					Nodes: []LanguageNode{
+						&Literal{"DESC"},
+					},
+				},
+				Else: &SyntaxTree{
+					Nodes: []LanguageNode{
+						&Literal{"ASC"},
+					},
+				},
+			},
+		},
+	}
+	cases := []struct {
+		desc      string
+		inputVars map[string]interface{}
+		expected  string
+	}{
+		{
+			desc:      "Missing variables take default values",
+			inputVars: map[string]interface{}{},
+			expected:  "LIMIT 50 DESC",
+		},
+		{
+			desc: "Zero value takes default value in a clause, but not in a predicate",
+			inputVars: map[string]interface{}{
+				".Limit": 0,
+				".Desc":  false,
+			},
+			expected: "LIMIT 50 ASC",
+		},
+		{
+			desc: "Nil takes default value",
+			inputVars: map[string]interface{}{
+				".Limit": nil,
+			},
+			expected: "LIMIT 50 DESC",
+		},
+		{
+			desc: "Given variables",
+			inputVars: map[string]interface{}{
+				".Limit": 10,
+				".Desc":  true,
+			},
+			expected: "LIMIT 10 DESC",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			output, err := inputSyntaxTree.Evaluate(&Env{
+				Vars:       c.inputVars,
+				MissingKey: MissingKeyError,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output != c.expected {
+				t.Fatalf("Expected %v but got %v", c.expected, output)
+			}
+		})
+	}
+}
+
+func TestSyntaxTree_EvaluatePredicatePipeline(t *testing.T) {
+	cases := []struct {
+		desc     string
+		input    string
+		isError  bool
+		expected string
+	}{
+		{
+			desc:     "Truthy",
+			input:    `ABC {{ [if] .Role | is "admin" [then] DEF [else] GHI }}`,
+			expected: "ABC DEF",
+		},
+		{
+			desc:     "Falsey",
+			input:    `ABC {{ [if] .Role | upper | is "admin" [then] DEF [else] GHI }}`,
+			expected: "ABC GHI",
+		},
+		{
+			desc:    "Not a boolean",
+			input:   `ABC {{ [if] .Role | upper [then] DEF }}`,
+			isError: true,
+		},
+		{
+			desc:    "Default in a pipeline",
+			input:   `ABC {{ [if] .Role [default] true | upper [then] DEF }}`,
+			isError: true,
+		},
+	}
+
+	env := &Env{
+		Vars:  map[string]interface{}{".Role": "admin"},
+		Funcs: FuncMap{"is": func(want, got string) bool { return want == got }},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var output string
+			st, err := tt.Parse()
+			if err == nil {
+				output, err = st.Evaluate(env)
+			}
+			if err != nil {
+				if !c.isError {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			} else if c.isError {
+				t.Fatalf("Expected error but got nil error")
+			}
+			if output != c.expected {
+				t.Fatalf("Expected %v but got %v", c.expected, output)
+			}
+		})
+	}
+}
+
+func TestSyntaxTree_EvaluateDecidedPredicate(t *testing.T) {
+	cases := []struct {
+		desc     string
+		input    string
+		expected string
+	}{
+		{
+			desc:     "Predicate decided over its value",
+			input:    `ABC {{ [if] .A [then] DEF [else] GHI }}`,
+			expected: "ABC GHI",
+		},
+		{
+			desc:     "Missing predicate",
+			input:    `ABC {{ [if] .Missing [then] DEF [else] GHI }}`,
+			expected: "ABC DEF",
+		},
+		{
+			desc:     "Pipeline",
+			input:    `ABC {{ [if] .Role | isAdmin [then] DEF [else] GHI }}`,
+			expected: "ABC DEF",
+		},
+	}
+
+	decided := map[string]bool{".A": false, ".Missing": true, ".Role | isAdmin": true}
+	env := &Env{
+		Vars:       map[string]interface{}{".A": true},
+		MissingKey: MissingKeyError,
+		Predicate:  func(predicate string) bool { return decided[predicate] },
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			st, err := tt.Parse()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			output, err := st.Evaluate(env)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
 			if output != c.expected {
 				t.Fatalf("Expected %v but got %v", c.expected, output)
 			}
 		})
 	}
 }
+
+func TestSyntaxTree_Variables(t *testing.T) {
+	st := &SyntaxTree{
+		Nodes: []LanguageNode{
+			&Literal{"ABC"},
+			&Literal{".Column"},
+			&IfBlock{
+				PredicateExpr: []string{".IncludeReviews"},
+				Then: &SyntaxTree{
+					Nodes: []LanguageNode{
+						&Literal{".Reviews"},
+					},
+				},
+				Else: &SyntaxTree{
+					Nodes: []LanguageNode{
+						&IfBlock{
+							PredicateExpr: []string{"true"},
+							Then: &SyntaxTree{
+								Nodes: []LanguageNode{
+									&Literal{".Column"},
+								},
+							},
+						},
+					},
+				},
+			},
+			&Literal{"table.column"},
+			&DefaultBlock{
+				Name:  ".Limit",
+				Value: []string{"50"},
+			},
+		},
+	}
+	expected := []Variable{
+		{Name: ".Column", Value: true},
+		{Name: ".IncludeReviews", Predicate: true},
+		{Name: ".Reviews", Value: true},
+		{Name: ".Column", Value: true},
+		{Name: ".Limit", HasDefault: true, Value: true},
+	}
+	if diff := deep.Equal(expected, st.Variables()); diff != nil {
+		t.Errorf("Wrong result: %v", diff)
+	}
+}
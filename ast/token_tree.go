@@ -1,41 +1,116 @@
 package ast
 
 import (
-	"strings"
+	stderrors "errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
 
 // BuildTokenTree builds a tree of tokens from a template string.
-func BuildTokenTree(q string) *TokenTree {
+// It returns an *Error if the {{ and }} delimiters in the template are not
+// balanced.
+func BuildTokenTree(q string) (*TokenTree, error) {
 	tt := &TokenTree{}
-	for _, token := range strings.Fields(q) {
-		if token == keywordLanguageStart {
-			child := &TokenTree{parent: tt}
+	for _, tok := range Tokenize(q) {
+		if tok.Text == keywordLanguageStart {
+			child := &TokenTree{parent: tt, pos: tok.Pos}
 			tt.chunks = append(tt.chunks, child)
 			tt = child
 			continue
 		}
 
-		if token == keywordLanguageEnd {
+		if tok.Text == keywordLanguageEnd {
+			if tt.parent == nil {
+				return nil, &Error{
+					Pos: tok.Pos,
+					Msg: fmt.Sprintf("unexpected %s without matching %s", keywordLanguageEnd, keywordLanguageStart),
+				}
+			}
 			tt = tt.parent
 			continue
 		}
 
-		tt.chunks = append(tt.chunks, &literal{token})
+		tt.chunks = append(tt.chunks, &Literal{tok.Text})
 	}
 
-	return tt
+	if tt.parent != nil {
+		return nil, &Error{
+			Pos: tt.pos,
+			Msg: fmt.Sprintf("unclosed %s", keywordLanguageStart),
+		}
+	}
+
+	return tt, nil
+}
+
+// Token is a whitespace separated word in the template, along with the
+// position it starts at.
+type Token struct {
+	Text string
+	Pos  Pos
+}
+
+// Tokenize splits the template into whitespace separated tokens, the same way
+// strings.Fields does, keeping track of the position of each token.
+func Tokenize(q string) []Token {
+	var (
+		tokens []Token
+		pos    = Pos{Line: 1, Column: 1}
+		start  = -1
+		begin  Pos
+	)
+	for i := 0; i < len(q); {
+		r, size := utf8.DecodeRuneInString(q[i:])
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				tokens = append(tokens, Token{Text: q[start:i], Pos: begin})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+			begin = pos
+		}
+
+		i += size
+		pos.Offset = i
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column += size
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Text: q[start:], Pos: begin})
+	}
+	return tokens
 }
 
 // TokenTree represents a tree of token chunks.
 type TokenTree struct {
 	chunks []chunk
 	parent *TokenTree
+	pos    Pos
 }
 
 // Parse parses the TokenTree and returns the AST built from it.
+// An error in an expression is an *Error at the position of the innermost
+// expression it's found in.
 func (tt *TokenTree) Parse() (LanguageNode, error) {
+	node, err := tt.parse()
+	if err != nil && tt.parent != nil {
+		var posErr *Error
+		if !stderrors.As(err, &posErr) {
+			return nil, &Error{Pos: tt.pos, Msg: err.Error()}
+		}
+	}
+	return node, err
+}
+
+func (tt *TokenTree) parse() (LanguageNode, error) {
 	isIf, err := isIfBlock(tt)
 	if err != nil {
 		return nil, errors.Wrap(err, "checking an expression for if block")
@@ -45,18 +120,102 @@ func (tt *TokenTree) Parse() (LanguageNode, error) {
 		if err != nil {
 			return nil, errors.Wrap(err, "parsing an expression for if block")
 		}
-		return &SyntaxTree{children: []LanguageNode{ifBlock}}, nil
+		return &SyntaxTree{Nodes: []LanguageNode{ifBlock}}, nil
+	}
+
+	isParams, err := isParamsBlock(tt)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking an expression for params block")
+	}
+	if isParams {
+		paramsBlock, err := parseParamsBlock(tt)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing an expression for params block")
+		}
+		return &SyntaxTree{Nodes: []LanguageNode{paramsBlock}}, nil
+	}
+
+	isDefine, err := isDefineBlock(tt)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking an expression for define block")
+	}
+	if isDefine {
+		defineBlock, err := parseDefineBlock(tt)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing an expression for define block")
+		}
+		return &SyntaxTree{Nodes: []LanguageNode{defineBlock}}, nil
+	}
+
+	isInclude, err := isIncludeBlock(tt)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking an expression for include block")
+	}
+	if isInclude {
+		return &SyntaxTree{Nodes: []LanguageNode{parseIncludeBlock(tt)}}, nil
+	}
+
+	isDefault, err := isDefaultBlock(tt)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking an expression for default block")
+	}
+	if isDefault {
+		return &SyntaxTree{Nodes: []LanguageNode{parseDefaultBlock(tt)}}, nil
+	}
+
+	isIdent, err := isIdentBlock(tt)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking an expression for ident block")
+	}
+	if isIdent {
+		return &SyntaxTree{Nodes: []LanguageNode{parseIdentBlock(tt)}}, nil
+	}
+
+	isRaw, err := isRawBlock(tt)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking an expression for raw block")
+	}
+	if isRaw {
+		return &SyntaxTree{Nodes: []LanguageNode{parseRawBlock(tt)}}, nil
+	}
+
+	isAllowlist, err := isAllowlistBlock(tt)
+	if err != nil {
+		return nil, errors.Wrap(err, "checking an expression for allowlist block")
+	}
+	if isAllowlist {
+		allowlistBlock, err := parseAllowlistBlock(tt)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing an expression for allowlist block")
+		}
+		return &SyntaxTree{Nodes: []LanguageNode{allowlistBlock}}, nil
+	}
+
+	// A | outside of an expression is an operator of the SQL, such as a
+	// bitwise or.
+	isPipe := false
+	if tt.parent != nil {
+		if isPipe, err = isPipeline(tt); err != nil {
+			return nil, errors.Wrap(err, "checking an expression for pipeline")
+		}
+	}
+	if isPipe {
+		pipeline, err := parsePipeline(tt)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing an expression for pipeline")
+		}
+		return &SyntaxTree{Nodes: []LanguageNode{pipeline}}, nil
 	}
 
 	st := &SyntaxTree{
-		children: make([]LanguageNode, 0, len(tt.chunks)),
+		Nodes: make([]LanguageNode, 0, len(tt.chunks)),
 	}
 	for _, chunk := range tt.chunks {
 		node, err := chunk.Parse()
 		if err != nil {
 			return nil, errors.Wrap(err, "building a node from token chunk")
 		}
-		st.children = append(st.children, node)
+		st.Nodes = append(st.Nodes, node)
 	}
 
 	return st, nil
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/go-test/deep"
@@ -8,20 +10,21 @@ import (
 
 func TestTokenTree_BuildTokenTree(t *testing.T) {
 	cases := []struct {
-		desc     string
-		input    string
-		expected *TokenTree
+		desc          string
+		input         string
+		expected      *TokenTree
+		expectedError error
 	}{
 		{
 			desc:  "Flat free",
 			input: `ABC DEF GHI JKL MNO`,
 			expected: &TokenTree{
 				chunks: []chunk{
-					&literal{"ABC"},
-					&literal{"DEF"},
-					&literal{"GHI"},
-					&literal{"JKL"},
-					&literal{"MNO"},
+					&Literal{"ABC"},
+					&Literal{"DEF"},
+					&Literal{"GHI"},
+					&Literal{"JKL"},
+					&Literal{"MNO"},
 				},
 			},
 		},
@@ -31,27 +34,27 @@ func TestTokenTree_BuildTokenTree(t *testing.T) {
 				{{ MNO PQR {{ [if] STU [then] VWX {{ YZ }} }} }}`,
 			expected: &TokenTree{
 				chunks: []chunk{
-					&literal{"ABC"},
+					&Literal{"ABC"},
 					&TokenTree{
 						chunks: []chunk{
-							&literal{"DEF"},
-							&literal{"GHI"},
+							&Literal{"DEF"},
+							&Literal{"GHI"},
 						},
 					},
-					&literal{"JKL"},
+					&Literal{"JKL"},
 					&TokenTree{
 						chunks: []chunk{
-							&literal{"MNO"},
-							&literal{"PQR"},
+							&Literal{"MNO"},
+							&Literal{"PQR"},
 							&TokenTree{
 								chunks: []chunk{
-									&literal{"[if]"},
-									&literal{"STU"},
-									&literal{"[then]"},
-									&literal{"VWX"},
+									&Literal{"[if]"},
+									&Literal{"STU"},
+									&Literal{"[then]"},
+									&Literal{"VWX"},
 									&TokenTree{
 										chunks: []chunk{
-											&literal{"YZ"},
+											&Literal{"YZ"},
 										},
 									},
 								},
@@ -61,11 +64,38 @@ func TestTokenTree_BuildTokenTree(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc:  "Unmatched closing delimiter",
+			input: "ABC {{ DEF }}\n  GHI }} JKL",
+			expectedError: &Error{
+				Pos: Pos{Offset: 20, Line: 2, Column: 7},
+				Msg: "unexpected }} without matching {{",
+			},
+		},
+		{
+			desc:  "Unclosed opening delimiter",
+			input: "ABC {{ DEF\n  {{ GHI }} JKL",
+			expectedError: &Error{
+				Pos: Pos{Offset: 4, Line: 1, Column: 5},
+				Msg: "unclosed {{",
+			},
+		},
+		{
+			desc:  "Unclosed nested opening delimiter",
+			input: "{{ [if] .A [then] {{ B }}\n{{ C }}",
+			expectedError: &Error{
+				Pos: Pos{Offset: 0, Line: 1, Column: 1},
+				Msg: "unclosed {{",
+			},
+		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.desc, func(t *testing.T) {
-			output := BuildTokenTree(c.input)
+			output, err := BuildTokenTree(c.input)
+			if diff := deep.Equal(c.expectedError, err); diff != nil {
+				t.Errorf("Wrong error: %v", diff)
+			}
 			if diff := deep.Equal(c.expected, output); diff != nil {
 				t.Errorf("Wrong result: %v", diff)
 			}
@@ -73,6 +103,76 @@ func TestTokenTree_BuildTokenTree(t *testing.T) {
 	}
 }
 
+func FuzzBuildTokenTree(f *testing.F) {
+	seeds := []string{
+		``,
+		`ABC DEF GHI`,
+		`ABC {{ DEF GHI }} JKL`,
+		`{{ [if] .A [then] B {{ [if] .C [then] D [else] E }} }}`,
+		`{{ {{ }}`,
+		`{{ }} }}`,
+		"}}\n{{",
+		"ABC\t{{\r\n}}\u00a0}}",
+	}
+	for _, s := range seeds {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, q string) {
+		var depth, opened int
+		balanced := true
+		for _, field := range strings.Fields(q) {
+			switch field {
+			case keywordLanguageStart:
+				depth++
+				opened++
+			case keywordLanguageEnd:
+				depth--
+			}
+			if depth < 0 {
+				balanced = false
+			}
+		}
+		balanced = balanced && depth == 0
+
+		tt, err := BuildTokenTree(q)
+		if balanced {
+			if err != nil {
+				t.Fatalf("Unexpected error for balanced template %q: %v", q, err)
+			}
+			if n := countTokenTrees(tt) - 1; n != opened {
+				t.Fatalf("Expected %d expressions, got %d", opened, n)
+			}
+			return
+		}
+
+		var parseErr *Error
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("Expected *Error for unbalanced template %q, got %v", q, err)
+		}
+		p := parseErr.Pos
+		if p.Offset < 0 || p.Offset+len(keywordLanguageEnd) > len(q) {
+			t.Fatalf("Error position %+v out of range for %q", p, q)
+		}
+		if d := q[p.Offset : p.Offset+len(keywordLanguageEnd)]; d != keywordLanguageStart && d != keywordLanguageEnd {
+			t.Fatalf("Error position %+v does not point at a delimiter in %q", p, q)
+		}
+		if line := strings.Count(q[:p.Offset], "\n") + 1; line != p.Line {
+			t.Fatalf("Expected line %d, got %d", line, p.Line)
+		}
+	})
+}
+
+func countTokenTrees(tt *TokenTree) int {
+	n := 1
+	for _, c := range tt.chunks {
+		if child, ok := c.(*TokenTree); ok {
+			n += countTokenTrees(child)
+		}
+	}
+	return n
+}
+
 func TestBuildTokenTree_Parse(t *testing.T) {
 	cases := []struct {
 		desc           string
@@ -89,27 +189,27 @@ func TestBuildTokenTree_Parse(t *testing.T) {
 			desc: "Token tree with if blocks",
 			inputTokenTree: &TokenTree{
 				chunks: []chunk{
-					&literal{"ABC"},
+					&Literal{"ABC"},
 					&TokenTree{
 						chunks: []chunk{
-							&literal{"DEF"},
-							&literal{"GHI"},
+							&Literal{"DEF"},
+							&Literal{"GHI"},
 						},
 					},
-					&literal{"JKL"},
+					&Literal{"JKL"},
 					&TokenTree{
 						chunks: []chunk{
-							&literal{"MNO"},
-							&literal{"PQR"},
+							&Literal{"MNO"},
+							&Literal{"PQR"},
 							&TokenTree{
 								chunks: []chunk{
-									&literal{"[if]"},
-									&literal{"STU"},
-									&literal{"[then]"},
-									&literal{"VWX"},
+									&Literal{"[if]"},
+									&Literal{"STU"},
+									&Literal{"[then]"},
+									&Literal{"VWX"},
 									&TokenTree{
 										chunks: []chunk{
-											&literal{"YZ"},
+											&Literal{"YZ"},
 										},
 									},
 								},
@@ -119,29 +219,29 @@ func TestBuildTokenTree_Parse(t *testing.T) {
 				},
 			},
 			expected: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"ABC"},
+				Nodes: []LanguageNode{
+					&Literal{"ABC"},
 					&SyntaxTree{
-						children: []LanguageNode{
-							&literal{"DEF"},
-							&literal{"GHI"},
+						Nodes: []LanguageNode{
+							&Literal{"DEF"},
+							&Literal{"GHI"},
 						},
 					},
-					&literal{"JKL"},
+					&Literal{"JKL"},
 					&SyntaxTree{
-						children: []LanguageNode{
-							&literal{"MNO"},
-							&literal{"PQR"},
+						Nodes: []LanguageNode{
+							&Literal{"MNO"},
+							&Literal{"PQR"},
 							&SyntaxTree{
-								children: []LanguageNode{
-									&ifBlock{
-										predicateExpr: []string{"STU"},
-										then: &SyntaxTree{
-											children: []LanguageNode{
-												&literal{"VWX"},
+								Nodes: []LanguageNode{
+									&IfBlock{
+										PredicateExpr: []string{"STU"},
+										Then: &SyntaxTree{
+											Nodes: []LanguageNode{
+												&Literal{"VWX"},
 												&SyntaxTree{
-													children: []LanguageNode{
-														&literal{"YZ"},
+													Nodes: []LanguageNode{
+														&Literal{"YZ"},
 													},
 												},
 											},
@@ -158,34 +258,34 @@ func TestBuildTokenTree_Parse(t *testing.T) {
 			desc: "Token tree with if-else block",
 			inputTokenTree: &TokenTree{
 				chunks: []chunk{
-					&literal{"ABC"},
+					&Literal{"ABC"},
 					&TokenTree{
 						chunks: []chunk{
-							&literal{"DEF"},
-							&literal{"GHI"},
+							&Literal{"DEF"},
+							&Literal{"GHI"},
 						},
 					},
-					&literal{"JKL"},
+					&Literal{"JKL"},
 					&TokenTree{
 						chunks: []chunk{
-							&literal{"MNO"},
-							&literal{"PQR"},
+							&Literal{"MNO"},
+							&Literal{"PQR"},
 							&TokenTree{
 								chunks: []chunk{
-									&literal{"[if]"},
-									&literal{"STU"},
-									&literal{"[then]"},
-									&literal{"VWX"},
+									&Literal{"[if]"},
+									&Literal{"STU"},
+									&Literal{"[then]"},
+									&Literal{"VWX"},
 									&TokenTree{
 										chunks: []chunk{
-											&literal{"YZ"},
+											&Literal{"YZ"},
 										},
 									},
-									&literal{"[else]"},
-									&literal{"ABC"},
+									&Literal{"[else]"},
+									&Literal{"ABC"},
 									&TokenTree{
 										chunks: []chunk{
-											&literal{"DEF"},
+											&Literal{"DEF"},
 										},
 									},
 								},
@@ -195,39 +295,39 @@ func TestBuildTokenTree_Parse(t *testing.T) {
 				},
 			},
 			expected: &SyntaxTree{
-				children: []LanguageNode{
-					&literal{"ABC"},
+				Nodes: []LanguageNode{
+					&Literal{"ABC"},
 					&SyntaxTree{
-						children: []LanguageNode{
-							&literal{"DEF"},
-							&literal{"GHI"},
+						Nodes: []LanguageNode{
+							&Literal{"DEF"},
+							&Literal{"GHI"},
 						},
 					},
-					&literal{"JKL"},
+					&Literal{"JKL"},
 					&SyntaxTree{
-						children: []LanguageNode{
-							&literal{"MNO"},
-							&literal{"PQR"},
+						Nodes: []LanguageNode{
+							&Literal{"MNO"},
+							&Literal{"PQR"},
 							&SyntaxTree{
-								children: []LanguageNode{
-									&ifBlock{
-										predicateExpr: []string{"STU"},
-										then: &SyntaxTree{
-											children: []LanguageNode{
-												&literal{"VWX"},
+								Nodes: []LanguageNode{
+									&IfBlock{
+										PredicateExpr: []string{"STU"},
+										Then: &SyntaxTree{
+											Nodes: []LanguageNode{
+												&Literal{"VWX"},
 												&SyntaxTree{
-													children: []LanguageNode{
-														&literal{"YZ"},
+													Nodes: []LanguageNode{
+														&Literal{"YZ"},
 													},
 												},
 											},
 										},
-										otherwise: &SyntaxTree{
-											children: []LanguageNode{
-												&literal{"ABC"},
+										Else: &SyntaxTree{
+											Nodes: []LanguageNode{
+												&Literal{"ABC"},
 												&SyntaxTree{
-													children: []LanguageNode{
-														&literal{"DEF"},
+													Nodes: []LanguageNode{
+														&Literal{"DEF"},
 													},
 												},
 											},
@@ -240,6 +340,82 @@ func TestBuildTokenTree_Parse(t *testing.T) {
 				},
 			},
 		},
+		{
+			desc: "Token tree with default block",
+			inputTokenTree: &TokenTree{
+				chunks: []chunk{
+					&Literal{"LIMIT"},
+					&TokenTree{
+						chunks: []chunk{
+							&Literal{"[default]"},
+							&Literal{".Limit"},
+							&Literal{"50"},
+						},
+					},
+				},
+			},
+			expected: &SyntaxTree{
+				Nodes: []LanguageNode{
+					&Literal{"LIMIT"},
+					&SyntaxTree{
+						Nodes: []LanguageNode{
+							&DefaultBlock{
+								Name:  ".Limit",
+								Value: []string{"50"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "Default block without a variable",
+			inputTokenTree: &TokenTree{
+				chunks: []chunk{
+					&Literal{"[default]"},
+					&Literal{"50"},
+				},
+			},
+			isError: true,
+		},
+		{
+			desc: "Default block without a default value",
+			inputTokenTree: &TokenTree{
+				chunks: []chunk{
+					&Literal{"[default]"},
+					&Literal{".Limit"},
+				},
+			},
+			isError: true,
+		},
+		{
+			desc: "Default block with a nested expression",
+			inputTokenTree: &TokenTree{
+				chunks: []chunk{
+					&Literal{"[default]"},
+					&Literal{".Limit"},
+					&TokenTree{
+						chunks: []chunk{
+							&Literal{"50"},
+						},
+					},
+				},
+			},
+			isError: true,
+		},
+		{
+			desc: "Predicate default without a default value",
+			inputTokenTree: &TokenTree{
+				chunks: []chunk{
+					&Literal{"[if]"},
+					&Literal{".Desc"},
+					&Literal{"[default]"},
+					&Literal{"[then]"},
+					&Literal{"DESC"},
+				},
+			},
+			isError: true,
+		},
 	}
 
 	for _, c := range cases {
@@ -260,3 +436,69 @@ func TestBuildTokenTree_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenTree_ParseError(t *testing.T) {
+	cases := []struct {
+		desc          string
+		input         string
+		expectedError *Error
+	}{
+		{
+			desc:  "Invalid expression",
+			input: "ABC\n  {{ [include] }}",
+			expectedError: &Error{
+				Pos: Pos{Offset: 6, Line: 2, Column: 3},
+				Msg: "checking an expression for include block: [include] must be followed by a single name",
+			},
+		},
+		{
+			desc:  "Invalid nested expression",
+			input: "{{ [if] .A [then]\n  {{ [define] }} }}",
+			expectedError: &Error{
+				Pos: Pos{Offset: 20, Line: 2, Column: 3},
+				Msg: "checking an expression for define block: [define] must be at the top level of the template",
+			},
+		},
+		{
+			desc:  "Duplicated [else]",
+			input: "SELECT {{ [if] .A [then] a [else] b [else] c }}",
+			expectedError: &Error{
+				Pos: Pos{Offset: 7, Line: 1, Column: 8},
+				Msg: "parsing an expression for if block: [if] block has more than one [else]",
+			},
+		},
+		{
+			desc:  "[else] before [then]",
+			input: "SELECT {{ [if] .A [else] b [then] c }}",
+			expectedError: &Error{
+				Pos: Pos{Offset: 7, Line: 1, Column: 8},
+				Msg: "parsing an expression for if block: [else] must follow the [then] clause",
+			},
+		},
+		{
+			desc:  "Duplicated [then]",
+			input: "SELECT {{ [if] .A [then] a [then] b }}",
+			expectedError: &Error{
+				Pos: Pos{Offset: 7, Line: 1, Column: 8},
+				Msg: "parsing an expression for if block: [if] block has more than one [then]",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			_, err = tt.Parse()
+			var parseErr *Error
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("Expected *Error, got %v", err)
+			}
+			if diff := deep.Equal(c.expectedError, parseErr); diff != nil {
+				t.Errorf("Wrong error: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package ast
+
+import (
+	"database/sql/driver"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// driverValue returns the value v stands for in the database, if it's a
+// driver.Valuer such as sql.NullString. A NULL is returned as nil. Other
+// values are returned as-is.
+func driverValue(v interface{}) (interface{}, error) {
+	valuer, ok := v.(driver.Valuer)
+	if !ok {
+		return v, nil
+	}
+	// Like database/sql, treat a nil pointer as NULL rather than calling a
+	// method with a value receiver on it.
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	dv, err := valuer.Value()
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting the value of %T", v)
+	}
+	return dv, nil
+}
@@ -0,0 +1,128 @@
+package ast
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+type failingValuer struct{}
+
+func (failingValuer) Value() (driver.Value, error) {
+	return nil, errors.New("boom")
+}
+
+type money struct {
+	cents int64
+}
+
+func (m *money) Value() (driver.Value, error) {
+	return m.cents, nil
+}
+
+func TestSyntaxTree_EvaluateValuer(t *testing.T) {
+	var nilMoney *money
+	cases := []struct {
+		desc     string
+		input    string
+		inputEnv *Env
+		expected string
+		isError  bool
+	}{
+		{
+			desc:     "Valid value",
+			input:    `WHERE name = .Name`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Name": sql.NullString{String: "apple", Valid: true}}},
+			expected: "WHERE name = apple",
+		},
+		{
+			desc:     "NULL",
+			input:    `WHERE name = .Name`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Name": sql.NullString{}}},
+			expected: "WHERE name = NULL",
+		},
+		{
+			desc:     "Inline literal",
+			input:    `WHERE name = .Name AND price = .Price`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Name": sql.NullString{String: "it's", Valid: true}, ".Price": &money{150}}, Inline: true},
+			expected: "WHERE name = 'it''s' AND price = 150",
+		},
+		{
+			desc:     "Inline NULL",
+			input:    `WHERE price = .Price`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Price": nilMoney}, Inline: true},
+			expected: "WHERE price = NULL",
+		},
+		{
+			desc:     "Valid predicate",
+			input:    `{{ [if] .Name [then] AND name IS NOT NULL }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Name": sql.NullString{String: "", Valid: true}}},
+			expected: "AND name IS NOT NULL",
+		},
+		{
+			desc:     "NULL predicate",
+			input:    `{{ [if] .Name [then] AND name IS NOT NULL }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Name": sql.NullString{}}},
+			expected: "",
+		},
+		{
+			desc:     "Boolean predicate",
+			input:    `{{ [if] .Active [then] AND active [else] AND NOT active }}`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Active": sql.NullBool{Bool: false, Valid: true}}},
+			expected: "AND NOT active",
+		},
+		{
+			desc:     "Error",
+			input:    `WHERE name = .Name`,
+			inputEnv: &Env{Vars: map[string]interface{}{".Name": failingValuer{}}},
+			isError:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tt, err := BuildTokenTree(c.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			st, err := tt.Parse()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := st.Evaluate(c.inputEnv)
+			if c.isError {
+				if err == nil {
+					t.Errorf("Expected error, got %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if result != c.expected {
+				t.Errorf("Expected %s, got %s", c.expected, result)
+			}
+		})
+	}
+}
+
+func TestParam_ResolveValuer(t *testing.T) {
+	p := Param{Name: ".Name", Type: "string", Required: true}
+
+	v := sql.NullString{String: "apple", Valid: true}
+	resolved, err := p.Resolve(v, true)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if resolved != v {
+		t.Errorf("Expected %v, got %v", v, resolved)
+	}
+
+	if _, err = p.Resolve(sql.NullString{}, true); err == nil {
+		t.Error("Expected error for a NULL required parameter, got nil")
+	}
+	if _, err = p.Resolve(sql.NullInt64{Int64: 1, Valid: true}, true); err == nil {
+		t.Error("Expected error for a mismatching type, got nil")
+	}
+}
@@ -0,0 +1,54 @@
+package ast
+
+// A Visitor's Visit method is called for each node encountered by Walk. If
+// the visitor w it returns is not nil, Walk visits each of the children of
+// the node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node LanguageNode) (w Visitor)
+}
+
+// Walk traverses the tree of node depth-first, starting with a call of
+// v.Visit(node). It enters the nodes of SyntaxTrees, both clauses of [if]
+// blocks, including the ones which wouldn't be evaluated, and the bodies of
+// [define] blocks. It doesn't enter included partials, which are walked on
+// their own.
+func Walk(node LanguageNode, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *SyntaxTree:
+		if n != nil {
+			for _, child := range n.Nodes {
+				Walk(child, v)
+			}
+		}
+	case *IfBlock:
+		if n.Then != nil {
+			Walk(n.Then, v)
+		}
+		if n.Else != nil {
+			Walk(n.Else, v)
+		}
+	case *DefineBlock:
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	}
+	v.Visit(nil)
+}
+
+type inspector func(LanguageNode)
+
+func (f inspector) Visit(node LanguageNode) Visitor {
+	if node == nil {
+		return nil
+	}
+	f(node)
+	return f
+}
+
+// walk calls fn for n and each of the nodes under it, in the order of Walk.
+func walk(n LanguageNode, fn func(LanguageNode)) {
+	Walk(n, inspector(fn))
+}
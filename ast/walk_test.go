@@ -0,0 +1,64 @@
+package ast
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+// recorder records the nodes it visits, with the depth they're visited at.
+type recorder struct {
+	depth int
+	nodes *[]string
+}
+
+func (r recorder) Visit(node LanguageNode) Visitor {
+	if node == nil {
+		*r.nodes = append(*r.nodes, fmt.Sprintf("%d end", r.depth))
+		return nil
+	}
+	*r.nodes = append(*r.nodes, fmt.Sprintf("%d %T", r.depth, node))
+	if _, ok := node.(*IncludeBlock); ok {
+		return nil
+	}
+	return recorder{depth: r.depth + 1, nodes: r.nodes}
+}
+
+func TestWalk(t *testing.T) {
+	st := mustParse(t, `{{ [define] p x }} a {{ [if] .A [then] {{ [include] p }} [else] b }}`)
+	var nodes []string
+	Walk(st, recorder{nodes: &nodes})
+
+	expected := []string{
+		"0 *ast.SyntaxTree",
+		"1 *ast.SyntaxTree",
+		"2 *ast.DefineBlock",
+		"3 *ast.SyntaxTree",
+		"4 *ast.Literal",
+		"5 end",
+		"4 end",
+		"3 end",
+		"2 end",
+		"1 *ast.Literal",
+		"2 end",
+		"1 *ast.SyntaxTree",
+		"2 *ast.IfBlock",
+		"3 *ast.SyntaxTree",
+		"4 *ast.SyntaxTree",
+		// The included partial isn't entered.
+		"5 *ast.IncludeBlock",
+		"5 end",
+		"4 end",
+		"3 *ast.SyntaxTree",
+		"4 *ast.Literal",
+		"5 end",
+		"4 end",
+		"3 end",
+		"2 end",
+		"1 end",
+	}
+	if diff := deep.Equal(expected, nodes); diff != nil {
+		t.Errorf("Wrong result: %v", diff)
+	}
+}
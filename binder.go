@@ -0,0 +1,144 @@
+package gosq
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// Binder provides SQL-aware functions for the text/template syntax, and
+// collects the args bound by them. ExecuteArgs uses a Binder internally, but
+// its functions can be added to any text/template as well:
+//
+//	b := &gosq.Binder{Dialect: gosq.Postgres}
+//	tmpl := template.Must(template.New("q").Funcs(b.FuncMap()).Parse(
+//		`SELECT * FROM {{ ident .Table }} WHERE id IN {{ in .IDs }}`,
+//	))
+//	err := tmpl.Execute(&buf, args)
+//	rows, err := db.Query(buf.String(), b.Args...)
+//
+// A Binder must not be used by concurrent executions.
+type Binder struct {
+	Dialect Dialect
+	// Args are the args bound so far, in the order of their placeholders.
+	Args []interface{}
+
+	// inline makes the auto-escaping inline values as literals instead of
+	// binding them.
+	inline bool
+}
+
+// FuncMap returns the functions of the Binder:
+//   - bind: binds the value as an arg, and returns its placeholder
+//   - in: binds each element of the slice, and returns the parenthesized list
+//     of their placeholders. An empty slice is an error, since there's no
+//     empty list in SQL, and (NULL) would make NOT IN never true
+//   - ident: quotes an identifier, which may be qualified with a schema
+//   - literal: returns the value as a SQL literal, quoted and escaped
+//   - join: joins the elements of a slice with a separator
+func (b *Binder) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"bind":    b.bind,
+		"in":      b.in,
+		"ident":   b.ident,
+		"literal": b.literal,
+		"join":    ast.Builtins()["join"],
+	}
+}
+
+// escapers returns the functions appended to the actions by the
+// auto-escaping, see escapeTemplate.
+func (b *Binder) escapers() template.FuncMap {
+	return template.FuncMap{
+		escapeValueFunc:       b.escapeValue,
+		escapeIdentFunc:       b.ident,
+		escapeStringFunc:      b.escapeString,
+		escapeBackslashFunc:   escapeBackslashString,
+		escapeQuotedIdentFunc: escapeQuotedIdent,
+	}
+}
+
+func (b *Binder) escapeValue(v interface{}) (string, error) {
+	if r, ok := v.(Raw); ok {
+		return string(r), nil
+	}
+	rv := reflect.ValueOf(v)
+	isList := (rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8) || rv.Kind() == reflect.Array
+	if _, isValuer := v.(driver.Valuer); isValuer {
+		// A driver.Valuer of a slice type, such as an array of the database,
+		// is a single value.
+		isList = false
+	}
+	switch {
+	case isList && b.inline:
+		if rv.Len() == 0 {
+			return "", errEmptyList
+		}
+		literals := make([]string, rv.Len())
+		for i := range literals {
+			l, err := b.literal(rv.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			literals[i] = l
+		}
+		return "(" + strings.Join(literals, ", ") + ")", nil
+	case isList:
+		return b.in(v)
+	case b.inline:
+		return b.literal(v)
+	}
+	return b.bind(v), nil
+}
+
+func (b *Binder) escapeString(delim string, v interface{}) string {
+	return b.Dialect.EscapeString(fmt.Sprint(v), delim[0])
+}
+
+// escapeBackslashString escapes a value inside a string literal where
+// backslashes are escape characters, whatever the dialect.
+func escapeBackslashString(delim string, v interface{}) string {
+	s := strings.ReplaceAll(fmt.Sprint(v), `\`, `\\`)
+	return strings.ReplaceAll(s, delim, delim+delim)
+}
+
+func escapeQuotedIdent(delim string, v interface{}) string {
+	return strings.ReplaceAll(fmt.Sprint(v), delim, delim+delim)
+}
+
+func (b *Binder) bind(v interface{}) string {
+	b.Args = append(b.Args, v)
+	return b.Dialect.Placeholder(len(b.Args))
+}
+
+// errEmptyList is the error of an empty slice written as a list. The query
+// should leave out the condition instead.
+var errEmptyList = errors.New("empty slice can't be a list, since NOT IN (NULL) would never be true")
+
+func (b *Binder) in(v interface{}) (string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", errors.Errorf("in expects a slice, got %T", v)
+	}
+	if rv.Len() == 0 {
+		return "", errEmptyList
+	}
+	placeholders := make([]string, rv.Len())
+	for i := range placeholders {
+		placeholders[i] = b.bind(rv.Index(i).Interface())
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")", nil
+}
+
+func (b *Binder) ident(name interface{}) (string, error) {
+	return b.Dialect.QuoteIdent(fmt.Sprint(name))
+}
+
+func (b *Binder) literal(v interface{}) (string, error) {
+	return b.Dialect.Literal(v)
+}
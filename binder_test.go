@@ -0,0 +1,132 @@
+package gosq_test
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/sanggonlee/gosq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteArgs(t *testing.T) {
+	inputTemplate := `
+		SELECT {{ ident .Column }}
+		FROM products
+		WHERE category = {{ bind .Category }}
+		AND id IN {{ in .IDs }}
+		{{ if .Status }}AND status = {{ literal .Status }}{{ end }}
+	`
+	cases := []struct {
+		desc          string
+		inputArgs     map[string]interface{}
+		inputOptions  []gosq.Option
+		expected      string
+		expectedArgs  []interface{}
+		expectedError string
+	}{
+		{
+			desc: "Postgres",
+			inputArgs: map[string]interface{}{
+				"Column":   "name",
+				"Category": "'; DROP TABLE products; --",
+				"IDs":      []int{1, 2},
+				"Status":   "it's",
+			},
+			expected:     `SELECT "name" FROM products WHERE category = $1 AND id IN ($2, $3) AND status = 'it''s'`,
+			expectedArgs: []interface{}{"'; DROP TABLE products; --", 1, 2},
+		},
+		{
+			desc: "MySQL",
+			inputArgs: map[string]interface{}{
+				"Column":   "name",
+				"Category": "food",
+				"IDs":      []string{"a"},
+				"Status":   "",
+			},
+			inputOptions: []gosq.Option{gosq.WithDialect(gosq.MySQL)},
+			expected:     "SELECT `name` FROM products WHERE category = ? AND id IN (?)",
+			expectedArgs: []interface{}{"food", "a"},
+		},
+		{
+			desc: "SQLServer",
+			inputArgs: map[string]interface{}{
+				"Column":   "dbo.name",
+				"Category": "food",
+				"IDs":      []int{3},
+				"Status":   "",
+			},
+			inputOptions: []gosq.Option{gosq.WithDialect(gosq.SQLServer)},
+			expected:     "SELECT [dbo].[name] FROM products WHERE category = @p1 AND id IN (@p2)",
+			expectedArgs: []interface{}{"food", 3},
+		},
+		{
+			desc: "Invalid identifier",
+			inputArgs: map[string]interface{}{
+				"Column":   `name"; --`,
+				"Category": "food",
+				"IDs":      []int{1},
+				"Status":   "",
+			},
+			expectedError: "invalid identifier",
+		},
+		{
+			desc: "In expects a slice",
+			inputArgs: map[string]interface{}{
+				"Column":   "name",
+				"Category": "food",
+				"IDs":      1,
+				"Status":   "",
+			},
+			expectedError: "in expects a slice",
+		},
+		{
+			desc: "Empty slice",
+			inputArgs: map[string]interface{}{
+				"Column":   "name",
+				"Category": "food",
+				"IDs":      []int{},
+				"Status":   "",
+			},
+			expectedError: "empty slice can't be a list",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			opts := append([]gosq.Option{gosq.WithCompactWhitespace()}, c.inputOptions...)
+			result, args, err := gosq.ExecuteArgs(inputTemplate, c.inputArgs, opts...)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+			assert.Equal(t, c.expectedArgs, args)
+		})
+	}
+}
+
+func TestExecute_Bind(t *testing.T) {
+	_, err := gosq.Execute(`SELECT * FROM products WHERE id = {{ bind .ID }}`, map[string]interface{}{"ID": 1})
+	assert.ErrorContains(t, err, "use ExecuteArgs instead")
+
+	result, err := gosq.Execute(`SELECT * FROM {{ ident .Table }}`, map[string]interface{}{"Table": "products"})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "products"`, result)
+}
+
+func TestBinder_FuncMap(t *testing.T) {
+	b := &gosq.Binder{Dialect: gosq.SQLite}
+	tmpl := template.Must(template.New("q").Funcs(b.FuncMap()).Parse(
+		`SELECT * FROM products WHERE name = {{ bind .Name }} AND tag IN ({{ join ", " .Tags }})`,
+	))
+
+	var sb strings.Builder
+	err := tmpl.Execute(&sb, map[string]interface{}{
+		"Name": "apple",
+		"Tags": []string{"'a'", "'b'"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products WHERE name = ? AND tag IN ('a', 'b')`, sb.String())
+	assert.Equal(t, []interface{}{"apple"}, b.Args)
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	stderrors "errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// keywordRegex matches the words which look like keywords of the template
+// syntax, such as [if] or a misspelled [iff].
+var keywordRegex = regexp.MustCompile(`^\[[A-Za-z]+\]$`)
+
+// problem is a problem found in a template file.
+type problem struct {
+	file string
+	pos  ast.Pos
+	msg  string
+}
+
+func (p problem) String() string {
+	return fmt.Sprintf("%s:%s: %s", p.file, p.pos, p.msg)
+}
+
+// runLint checks the template files for problems, and prints each of them as
+// file:line:col: message:
+//
+//	gosq lint ./queries/...
+//	queries/products.sql:4:3: unknown keyword [iff]
+//
+// A path ending with /... is walked recursively for .sql files, a directory
+// is searched for .sql files, and a file is checked whatever its name. It
+// returns an error if any problem is found.
+func runLint(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	allowRaw := flags.Bool("allow-raw", false, "don't report [raw] substitutions")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	for _, p := range paths {
+		found, err := lintFiles(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+
+	var problems []problem
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return errors.Wrapf(err, "reading %s", file)
+		}
+		for _, p := range lint(string(src), *allowRaw) {
+			p.file = file
+			problems = append(problems, p)
+		}
+	}
+
+	for _, p := range problems {
+		fmt.Fprintln(stdout, p)
+	}
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("found 1 problem")
+	default:
+		return errors.Errorf("found %d problems", len(problems))
+	}
+}
+
+// lintFiles returns the files to check for the path, in lexical order.
+func lintFiles(path string) ([]string, error) {
+	recursive := path == "..." || strings.HasSuffix(path, "/...")
+	if recursive {
+		path = filepath.Clean(strings.TrimSuffix(path, "..."))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "checking %s", path)
+	}
+	if !info.IsDir() {
+		if recursive {
+			return nil, errors.Errorf("%s is not a directory", path)
+		}
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(p) == ".sql" {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "walking %s", path)
+	}
+	return files, nil
+}
+
+// lint returns the problems of the template src, in the order they appear.
+func lint(src string, allowRaw bool) []problem {
+	tt, err := ast.BuildTokenTree(src)
+	if err != nil {
+		// The expressions can't be told apart with unbalanced delimiters.
+		return []problem{parseProblem(err)}
+	}
+
+	var problems []problem
+	if _, err = tt.Parse(); err != nil {
+		problems = append(problems, parseProblem(err))
+	}
+	problems = append(problems, lintTokens(ast.Tokenize(src), allowRaw)...)
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].pos.Offset < problems[j].pos.Offset
+	})
+	return problems
+}
+
+// parseProblem returns the problem of an error building or parsing a
+// template, at the position it's found at if it's known.
+func parseProblem(err error) problem {
+	var posErr *ast.Error
+	if stderrors.As(err, &posErr) {
+		return problem{pos: posErr.Pos, msg: posErr.Msg}
+	}
+	return problem{pos: ast.Pos{Line: 1, Column: 1}, msg: err.Error()}
+}
+
+// lintExpr is the state of an expression being checked.
+type lintExpr struct {
+	// words is the number of words of the expression so far, counting a
+	// nested expression as a single word.
+	words int
+	isIf  bool
+	// then and els are the [then] and the [else] of an [if] block.
+	then, els *ast.Token
+	// clauseWords is the number of words of the current clause of an [if]
+	// block.
+	clauseWords int
+}
+
+// lintTokens checks the tokens of a template with balanced delimiters for
+// problems which don't prevent it from being parsed.
+func lintTokens(tokens []ast.Token, allowRaw bool) []problem {
+	var (
+		problems []problem
+		stack    []*lintExpr
+	)
+	report := func(tok ast.Token, format string, args ...interface{}) {
+		problems = append(problems, problem{pos: tok.Pos, msg: fmt.Sprintf(format, args...)})
+	}
+
+	for i, tok := range tokens {
+		var e *lintExpr
+		if len(stack) > 0 {
+			e = stack[len(stack)-1]
+		}
+		if tok.Text == "}}" {
+			if e == nil {
+				continue
+			}
+			switch {
+			case e.then != nil && e.els == nil && e.clauseWords == 0:
+				report(*e.then, "empty [then] clause")
+			case e.els != nil && e.clauseWords == 0:
+				report(*e.els, "empty [else] clause")
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if e == nil {
+			if tok.Text == "{{" {
+				stack = append(stack, &lintExpr{})
+			}
+			continue
+		}
+
+		e.words++
+		e.clauseWords++
+		switch {
+		case tok.Text == "{{":
+			stack = append(stack, &lintExpr{})
+		case e.words == 1 && tok.Text == "[if]":
+			e.isIf = true
+		case e.words == 1 && tok.Text == "[raw]" && !allowRaw:
+			name := "a variable"
+			if i+1 < len(tokens) && tokens[i+1].Text != "}}" {
+				name = tokens[i+1].Text
+			}
+			report(tok, "[raw] substitutes %s without escaping it", name)
+		case e.isIf && e.then == nil && tok.Text == "[then]":
+			e.then, e.clauseWords = &tokens[i], 0
+		case e.isIf && e.then != nil && e.els == nil && tok.Text == "[else]":
+			// A duplicated [else] is a parse error.
+			e.els, e.clauseWords = &tokens[i], 0
+		case keywordRegex.MatchString(tok.Text) && !ast.IsKeyword(tok.Text) &&
+			(e.words == 1 || (e.isIf && e.then == nil)):
+			report(tok, "unknown keyword %s", tok.Text)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"queries/valid.sql": `-- name: products
+SELECT * FROM products
+WHERE category = .Category
+{{ [if] .Sort [then] ORDER BY {{ [oneof] .Sort "name" "price" }} {{ [dir] .Dir }} }}`,
+		"queries/problems.sql": `SELECT * FROM products
+WHERE {{ [iff] .Category [then] category = .Category }}
+{{ [if] .OnlyNew [then] }}
+{{ [if] .Cheap [then] price < 10 [else] price >= 10 [else] TRUE }}
+{{ [if] .Reviewed [then] [else] }}
+{{ [raw] .Filter }}`,
+		"queries/nested/unbalanced.sql": "SELECT * FROM products\n  WHERE }}",
+		"queries/nested/invalid.sql":    "SELECT *\n  FROM {{ [include] }}",
+		"queries/notes.txt":             "{{ [iff] }}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	cases := []struct {
+		desc          string
+		args          []string
+		expected      string
+		expectedError string
+	}{
+		{
+			desc: "Valid file",
+			args: []string{path("queries/valid.sql")},
+		},
+		{
+			desc: "Problems",
+			args: []string{path("queries/problems.sql")},
+			expected: path("queries/problems.sql") + ":2:10: unknown keyword [iff]\n" +
+				path("queries/problems.sql") + ":3:18: empty [then] clause\n" +
+				path("queries/problems.sql") + ":4:1: parsing an expression for if block: [if] block has more than one [else]\n" +
+				path("queries/problems.sql") + ":5:26: empty [else] clause\n" +
+				path("queries/problems.sql") + ":6:4: [raw] substitutes .Filter without escaping it\n",
+			expectedError: "found 5 problems",
+		},
+		{
+			desc: "Raw allowed",
+			args: []string{"-allow-raw", path("queries/problems.sql")},
+			expected: path("queries/problems.sql") + ":2:10: unknown keyword [iff]\n" +
+				path("queries/problems.sql") + ":3:18: empty [then] clause\n" +
+				path("queries/problems.sql") + ":4:1: parsing an expression for if block: [if] block has more than one [else]\n" +
+				path("queries/problems.sql") + ":5:26: empty [else] clause\n",
+			expectedError: "found 4 problems",
+		},
+		{
+			desc:          "Directory",
+			args:          []string{path("queries/nested")},
+			expected:      path("queries/nested/invalid.sql") + ":2:8: checking an expression for include block: [include] must be followed by a single name\n" + path("queries/nested/unbalanced.sql") + ":2:9: unexpected }} without matching {{\n",
+			expectedError: "found 2 problems",
+		},
+		{
+			desc:          "Recursive",
+			args:          []string{path("queries") + "/..."},
+			expectedError: "found 7 problems",
+		},
+		{
+			desc:          "Missing path",
+			args:          []string{path("missing")},
+			expectedError: "checking " + path("missing"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runLint(c.args, strings.NewReader(""), &out)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			if c.expected != "" || c.expectedError == "" {
+				assert.Equal(t, c.expected, out.String())
+			}
+		})
+	}
+}
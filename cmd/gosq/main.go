@@ -0,0 +1,60 @@
+// Command gosq is a tool for working with gosq templates.
+//
+// Usage:
+//
+//	gosq <command> [flags]
+//
+// The commands are:
+//
+//	lint	check template files for problems
+//	render	render a template with args, and print the query and its args
+//
+// Run "gosq <command> -h" for the flags of a command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// commands are the subcommands, by name. Each runs with the arguments after
+// its name.
+var commands = map[string]func(args []string, stdin io.Reader, stdout io.Writer) error{
+	"lint":   runLint,
+	"render": runRender,
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gosq <command> [flags]\n\n")
+	fmt.Fprintf(os.Stderr, "The commands are:\n\n")
+	fmt.Fprintf(os.Stderr, "\tlint\tcheck template files for problems\n")
+	fmt.Fprintf(os.Stderr, "\trender\trender a template with args, and print the query and its args\n\n")
+	fmt.Fprintf(os.Stderr, "Run \"gosq <command> -h\" for the flags of a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	run, ok := commands[name]
+	if !ok {
+		if name != "help" && name != "-h" && name != "-help" {
+			fmt.Fprintf(os.Stderr, "gosq: unknown command %q\n", name)
+		}
+		usage()
+		os.Exit(2)
+	}
+
+	err := run(os.Args[2:], os.Stdin, os.Stdout)
+	switch {
+	case err == flag.ErrHelp:
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "gosq %s: %v\n", name, err)
+		os.Exit(1)
+	}
+}
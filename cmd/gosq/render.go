@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq"
+	"github.com/sanggonlee/gosq/ast"
+	"gopkg.in/yaml.v3"
+)
+
+// stringList is a flag which can be repeated.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// runRender renders a template with args, and prints the query followed by
+// its bound args as SQL comments:
+//
+//	gosq render -f query.sql -args '{"Category":"food"}'
+//	SELECT * FROM products WHERE category = $1
+//	-- $1 = 'food'
+//
+// The args are merged from the -args-file files in order, then -args, then
+// the -set flags.
+func runRender(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	var (
+		file        = flags.String("f", "", "template file, or - for the standard input")
+		name        = flags.String("name", "", "name of the query to render, if the file has -- name: headers")
+		argsJSON    = flags.String("args", "", "args as a JSON object")
+		dialect     = flags.String("dialect", "postgres", "SQL dialect: postgres, mysql, sqlite or sqlserver")
+		execute     = flags.Bool("execute", false, "render a text/template with Execute, rather than with Compile")
+		inline      = flags.Bool("inline", false, "inline the values as SQL literals rather than binding them")
+		argsFiles   stringList
+		assignments stringList
+	)
+	flags.Var(&argsFiles, "args-file", "JSON or YAML file of args, which can be repeated")
+	flags.Var(&assignments, "set", "arg as key=value, where the value is JSON, or a string otherwise, which can be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+
+	d, err := ast.ParseDialect(*dialect)
+	if err != nil {
+		return err
+	}
+	values := make(map[string]interface{})
+	for _, f := range argsFiles {
+		if err = readArgsFile(f, values); err != nil {
+			return err
+		}
+	}
+	if *argsJSON != "" {
+		if err = json.Unmarshal([]byte(*argsJSON), &values); err != nil {
+			return errors.Wrap(err, "parsing -args")
+		}
+	}
+	for _, a := range assignments {
+		if err = setArg(a, values); err != nil {
+			return err
+		}
+	}
+
+	// A missing arg is most likely a typo, so it's reported rather than
+	// left in the query.
+	opts := []gosq.Option{gosq.WithDialect(d), gosq.WithMissingKey(gosq.MissingKeyError)}
+	var (
+		query string
+		bound []interface{}
+	)
+	switch {
+	case *name != "":
+		query, bound, err = renderNamed(*file, *name, values, *inline, opts)
+	default:
+		var src []byte
+		if src, err = readFile(*file, stdin); err != nil {
+			return err
+		}
+		query, bound, err = render(string(src), values, *execute, *inline, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, strings.TrimSpace(query))
+	for i, v := range bound {
+		fmt.Fprintf(stdout, "-- %s = %s\n", argLabel(d, i+1), argString(d, v))
+	}
+	return nil
+}
+
+// render renders the template with Compile or Execute, binding the values
+// unless inline is set.
+func render(tmpl string, values map[string]interface{}, execute, inline bool, opts []gosq.Option) (string, []interface{}, error) {
+	switch {
+	case execute && inline:
+		q, err := gosq.Execute(tmpl, values, append(opts, gosq.WithAutoEscape())...)
+		return q, nil, err
+	case execute:
+		return gosq.ExecuteArgs(tmpl, values, append(opts, gosq.WithAutoEscape())...)
+	case inline:
+		q, err := gosq.Compile(tmpl, values, append(opts, gosq.WithInline())...)
+		return q, nil, err
+	}
+	return gosq.CompileArgs(tmpl, values, opts...)
+}
+
+// renderNamed renders the query of the given name of a file loaded with
+// gosq.LoadFS.
+func renderNamed(file, name string, values map[string]interface{}, inline bool, opts []gosq.Option) (string, []interface{}, error) {
+	if inline {
+		opts = append(opts, gosq.WithInline())
+	}
+	set, err := gosq.LoadFS(os.DirFS(filepath.Dir(file)), filepath.Base(file), opts...)
+	if err != nil {
+		return "", nil, err
+	}
+	tmpl, ok := set.Lookup(name)
+	if !ok {
+		return "", nil, errors.Errorf("query %s is not defined in %s", name, file)
+	}
+	if inline {
+		q, err := tmpl.Compile(values)
+		return q, nil, err
+	}
+	return tmpl.CompileArgs(values)
+}
+
+func readFile(file string, stdin io.Reader) ([]byte, error) {
+	if file == "-" {
+		b, err := io.ReadAll(stdin)
+		return b, errors.Wrap(err, "reading the standard input")
+	}
+	b, err := os.ReadFile(file)
+	return b, errors.Wrapf(err, "reading %s", file)
+}
+
+// readArgsFile reads the args of a JSON file, or of a YAML file if it has the
+// .yaml or .yml extension, into values.
+func readArgsFile(file string, values map[string]interface{}) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "reading %s", file)
+	}
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &values)
+	default:
+		err = json.Unmarshal(b, &values)
+	}
+	return errors.Wrapf(err, "parsing %s", file)
+}
+
+// setArg sets the arg of a key=value assignment in values. The value is
+// parsed as JSON, such as true, 10 or [1, 2], or taken as a string if it's
+// not valid JSON.
+func setArg(assignment string, values map[string]interface{}) error {
+	key, value, ok := strings.Cut(assignment, "=")
+	if !ok || key == "" {
+		return errors.Errorf("invalid -set %q, expected key=value", assignment)
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		v = value
+	}
+	values[key] = v
+	return nil
+}
+
+// argLabel returns the placeholder of the n-th arg, numbered if the
+// placeholders of the dialect aren't.
+func argLabel(d ast.Dialect, n int) string {
+	p := d.Placeholder(n)
+	if p == "?" {
+		return fmt.Sprintf("?%d", n)
+	}
+	return p
+}
+
+// argString returns the arg as a SQL literal, or as printed by fmt if it
+// can't be one.
+func argString(d ast.Dialect, v interface{}) string {
+	if s, err := d.Literal(v); err == nil {
+		return s
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunRender(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"query.sql": `SELECT * FROM products
+			WHERE category = .Category
+			{{ [if] .IncludeReviews [then] AND reviewed }}
+			LIMIT .Limit`,
+		"execute.sql": `SELECT * FROM products WHERE category = {{ .Category }}`,
+		"queries.sql": `-- name: count
+SELECT count(*) FROM products WHERE category = .Category
+
+-- name: list
+SELECT * FROM products WHERE category = .Category`,
+		"args.json": `{"Category": "food", "Limit": 10}`,
+		"args.yaml": "IncludeReviews: true\nLimit: 20\n",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	cases := []struct {
+		desc          string
+		args          []string
+		stdin         string
+		expected      string
+		expectedError string
+	}{
+		{
+			desc:     "JSON args",
+			args:     []string{"-f", path("query.sql"), "-args", `{"Category":"food","IncludeReviews":true,"Limit":10}`},
+			expected: "SELECT * FROM products WHERE category = $1 AND reviewed LIMIT $2\n-- $1 = 'food'\n-- $2 = 10\n",
+		},
+		{
+			desc:     "Args files and -set in order",
+			args:     []string{"-f", path("query.sql"), "-args-file", path("args.json"), "-args-file", path("args.yaml"), "-set", "Category=toys"},
+			expected: "SELECT * FROM products WHERE category = $1 AND reviewed LIMIT $2\n-- $1 = 'toys'\n-- $2 = 20\n",
+		},
+		{
+			desc:     "Inline with a dialect",
+			args:     []string{"-f", path("query.sql"), "-dialect", "mysql", "-inline", "-set", "Category=o'neil", "-set", "IncludeReviews=false", "-set", "Limit=5"},
+			expected: "SELECT * FROM products WHERE category = 'o''neil' LIMIT 5\n",
+		},
+		{
+			desc:     "Standard input",
+			args:     []string{"-f", "-", "-dialect", "sqlserver", "-set", "Category=food"},
+			stdin:    "SELECT * FROM products WHERE category = .Category",
+			expected: "SELECT * FROM products WHERE category = @p1\n-- @p1 = 'food'\n",
+		},
+		{
+			desc:     "Execute",
+			args:     []string{"-f", path("execute.sql"), "-execute", "-dialect", "sqlite", "-set", "Category=food"},
+			expected: "SELECT * FROM products WHERE category = ?\n-- ?1 = 'food'\n",
+		},
+		{
+			desc:     "Named query",
+			args:     []string{"-f", path("queries.sql"), "-name", "list", "-set", "Category=food"},
+			expected: "SELECT * FROM products WHERE category = $1\n-- $1 = 'food'\n",
+		},
+		{
+			desc:          "Unknown named query",
+			args:          []string{"-f", path("queries.sql"), "-name", "get", "-set", "Category=food"},
+			expectedError: "query get is not defined",
+		},
+		{
+			desc:          "Missing arg",
+			args:          []string{"-f", path("query.sql"), "-set", "Category=food"},
+			expectedError: "no value given for variable .IncludeReviews",
+		},
+		{
+			desc:          "Invalid -set",
+			args:          []string{"-f", path("query.sql"), "-set", "Category"},
+			expectedError: `invalid -set "Category"`,
+		},
+		{
+			desc:          "Unknown dialect",
+			args:          []string{"-f", path("query.sql"), "-dialect", "oracle"},
+			expectedError: `unknown dialect "oracle"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runRender(c.args, strings.NewReader(c.stdin), &out)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, out.String())
+		})
+	}
+}
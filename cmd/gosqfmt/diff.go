@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around a change.
+const contextLines = 3
+
+// edit is a line of a diff, prefixed with ' ' if it's unchanged, '-' if it's
+// removed or '+' if it's added.
+type edit struct {
+	op   byte
+	line string
+}
+
+// diff returns the changes from a to b as a unified diff of the file.
+func diff(name string, a, b []byte) string {
+	edits := lineEdits(splitLines(string(a)), splitLines(string(b)))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s.orig\n+++ %s\n", name, name)
+	for start := 0; start < len(edits); {
+		// Find the next change, and the end of the hunk around it.
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		end, unchanged := first, 0
+		for i := first; i < len(edits) && unchanged <= 2*contextLines; i++ {
+			if edits[i].op == ' ' {
+				unchanged++
+				continue
+			}
+			end, unchanged = i+1, 0
+		}
+
+		from, to := first-contextLines, end+contextLines
+		if from < start {
+			from = start
+		}
+		if to > len(edits) {
+			to = len(edits)
+		}
+		writeHunk(&sb, edits, from, to)
+		start = to
+	}
+	return sb.String()
+}
+
+// writeHunk writes the edits between from and to as a hunk.
+func writeHunk(sb *strings.Builder, edits []edit, from, to int) {
+	lineA, lineB := 1, 1
+	for _, e := range edits[:from] {
+		if e.op != '+' {
+			lineA++
+		}
+		if e.op != '-' {
+			lineB++
+		}
+	}
+	var lenA, lenB int
+	for _, e := range edits[from:to] {
+		if e.op != '+' {
+			lenA++
+		}
+		if e.op != '-' {
+			lenB++
+		}
+	}
+	// An empty range starts at the line before it.
+	if lenA == 0 {
+		lineA--
+	}
+	if lenB == 0 {
+		lineB--
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", lineA, lenA, lineB, lenB)
+	for _, e := range edits[from:to] {
+		sb.WriteByte(e.op)
+		sb.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// lineEdits returns the edits turning the lines a into b, keeping their
+// longest common subsequence unchanged.
+func lineEdits(a, b []string) []edit {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		}
+	}
+	return edits
+}
+
+// splitLines splits s into lines, each with its trailing newline if any.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
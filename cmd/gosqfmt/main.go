@@ -0,0 +1,131 @@
+// Command gosqfmt formats gosq templates in the canonical layout of package
+// format: single spaces between the keywords of an expression, and the lines
+// of a clause indented one level deeper than the line the expression opens on.
+// The SQL text is kept as it is.
+//
+// Usage:
+//
+//	gosqfmt [flags] [path ...]
+//
+// Without a path, it formats the standard input. A directory is formatted
+// recursively, for the files with the .sql extension. The flags are:
+//
+//	-d	display diffs instead of rewriting files
+//	-l	list files whose formatting differs from gosqfmt's
+//	-w	write the result to the source file instead of the standard output
+package main
+
+import (
+	"bytes"
+	stderrors "errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq/ast"
+	"github.com/sanggonlee/gosq/format"
+)
+
+type config struct {
+	diff  bool
+	list  bool
+	write bool
+}
+
+func main() {
+	var cfg config
+	flag.BoolVar(&cfg.diff, "d", false, "display diffs instead of rewriting files")
+	flag.BoolVar(&cfg.list, "l", false, "list files whose formatting differs from gosqfmt's")
+	flag.BoolVar(&cfg.write, "w", false, "write the result to the source file instead of the standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gosqfmt [flags] [path ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if cfg.write {
+			fmt.Fprintln(os.Stderr, "gosqfmt: can't use -w with the standard input")
+			os.Exit(2)
+		}
+		if err := process("<standard input>", os.Stdin, os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "gosqfmt: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var failed bool
+	for _, path := range flag.Args() {
+		if err := processPath(path, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "gosqfmt: %v\n", err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// processPath formats the file, or the .sql files under the directory.
+func processPath(path string, cfg config) error {
+	return filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (file != path && filepath.Ext(file) != ".sql") {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return process(file, f, os.Stdout, cfg)
+	})
+}
+
+// process formats the template read from in, writing the result to out, or
+// to the file with -w.
+func process(filename string, in io.Reader, out io.Writer, cfg config) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return errors.Wrapf(err, "reading %s", filename)
+	}
+	res, err := format.Source(src)
+	if err != nil {
+		var posErr *ast.Error
+		if stderrors.As(err, &posErr) {
+			return errors.Errorf("%s:%s: %s", filename, posErr.Pos, posErr.Msg)
+		}
+		return errors.Wrap(err, filename)
+	}
+
+	if !cfg.list && !cfg.write && !cfg.diff {
+		_, err = out.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if cfg.list {
+		fmt.Fprintln(out, filename)
+	}
+	if cfg.write {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if cfg.diff {
+		fmt.Fprint(out, diff(filename, src, res))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	unformatted = "SELECT *\nFROM products\n{{ [if]   .InStock [then]\nWHERE stock > 0 }}\nLIMIT 10\n"
+	formatted   = "SELECT *\nFROM products\n{{ [if] .InStock [then]\n  WHERE stock > 0 }}\nLIMIT 10\n"
+)
+
+func TestProcess(t *testing.T) {
+	cases := []struct {
+		desc     string
+		input    string
+		cfg      config
+		expected string
+	}{
+		{
+			desc:     "Print the result",
+			input:    unformatted,
+			expected: formatted,
+		},
+		{
+			desc:     "List a file to format",
+			input:    unformatted,
+			cfg:      config{list: true},
+			expected: "query.sql\n",
+		},
+		{
+			desc:  "List a formatted file",
+			input: formatted,
+			cfg:   config{list: true},
+		},
+		{
+			desc:  "Diff",
+			input: unformatted,
+			cfg:   config{diff: true},
+			expected: `--- query.sql.orig
++++ query.sql
+@@ -1,5 +1,5 @@
+ SELECT *
+ FROM products
+-{{ [if]   .InStock [then]
+-WHERE stock > 0 }}
++{{ [if] .InStock [then]
++  WHERE stock > 0 }}
+ LIMIT 10
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var out bytes.Buffer
+			err := process("query.sql", strings.NewReader(c.input), &out, c.cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, out.String())
+		})
+	}
+}
+
+func TestProcess_Write(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "query.sql")
+	assert.NoError(t, os.WriteFile(file, []byte(unformatted), 0644))
+
+	assert.NoError(t, processPath(filepath.Dir(file), config{write: true}))
+	b, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, formatted, string(b))
+}
+
+func TestProcess_Error(t *testing.T) {
+	err := process("query.sql", strings.NewReader("SELECT * FROM products\nWHERE {{ [if] .InStock"), &bytes.Buffer{}, config{})
+	assert.EqualError(t, err, "query.sql:2:7: unclosed {{")
+}
+
+func TestDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\n"
+	expected := `--- f.orig
++++ f
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -9,4 +9,4 @@
+ i
+ j
+ k
+-l
+\ No newline at end of file
++L
+`
+	assert.Equal(t, expected, diff("f", []byte(a), []byte(b)))
+}
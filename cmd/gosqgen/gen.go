@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"io/fs"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq"
+)
+
+// config is the configuration of the code generation.
+type config struct {
+	pkg     string
+	pattern string
+	dialect string
+}
+
+// dialects are the Go expressions of the dialects, by name.
+var dialects = map[string]string{
+	"postgres":  "gosq.Postgres",
+	"mysql":     "gosq.MySQL",
+	"sqlite":    "gosq.SQLite",
+	"sqlserver": "gosq.SQLServer",
+}
+
+// paramTypes are the Go types of the types of declared parameters.
+var paramTypes = map[string]string{
+	"string": "string",
+	"int":    "int",
+	"float":  "float64",
+	"bool":   "bool",
+	"any":    "interface{}",
+}
+
+type file struct {
+	Package string
+	Pattern string
+	Dialect string
+	Queries []query
+}
+
+type query struct {
+	// Name is the name of the query as a Go identifier.
+	Name string
+	// Query is the name of the query in the .sql files.
+	Query  string
+	Fields []field
+}
+
+type field struct {
+	Name string
+	Type string
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by gosqgen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"embed"
+
+	"github.com/sanggonlee/gosq"
+)
+
+//go:embed {{ .Pattern }}
+var gosqFiles embed.FS
+
+var gosqQueries = func() *gosq.TemplateSet {
+	set, err := gosq.LoadFS(gosqFiles, {{ printf "%q" .Pattern }}{{ with .Dialect }}, gosq.WithDialect({{ . }}){{ end }})
+	if err != nil {
+		panic(err)
+	}
+	return set
+}()
+{{ range .Queries }}
+// {{ .Name }}Params are the params of the {{ .Query }} query.
+type {{ .Name }}Params struct {
+{{- range .Fields }}
+	{{ .Name }} {{ .Type }}
+{{- end }}
+}
+
+// Build{{ .Name }} builds the {{ .Query }} query, and returns it along with the
+// args bound in it.
+func Build{{ .Name }}(p {{ .Name }}Params) (string, []interface{}, error) {
+	return gosqQueries.CompileArgs({{ printf "%q" .Query }}, p)
+}
+{{ end }}`))
+
+// generate returns the Go source of the functions for the queries of the
+// files of fsys matching the pattern.
+func generate(fsys fs.FS, cfg config) ([]byte, error) {
+	dialect, ok := dialects[cfg.dialect]
+	if !ok {
+		return nil, errors.Errorf("unknown dialect %q", cfg.dialect)
+	}
+	if dialect == dialects["postgres"] {
+		// Postgres is the default.
+		dialect = ""
+	}
+
+	set, err := gosq.LoadFS(fsys, cfg.pattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading queries")
+	}
+
+	f := file{
+		Package: cfg.pkg,
+		Pattern: cfg.pattern,
+		Dialect: dialect,
+	}
+	queries := make(map[string]string)
+	for _, name := range set.Names() {
+		tmpl, _ := set.Lookup(name)
+		q, err := newQuery(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := queries[q.Name]; ok {
+			return nil, errors.Errorf("queries %s and %s have the same Go name %s", other, name, q.Name)
+		}
+		queries[q.Name] = name
+		f.Queries = append(f.Queries, q)
+	}
+
+	var buf bytes.Buffer
+	if err = fileTemplate.Execute(&buf, f); err != nil {
+		return nil, errors.Wrap(err, "generating code")
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "formatting generated code")
+	}
+	return src, nil
+}
+
+// newQuery returns the query to generate code for, with a field for each of
+// the declared parameters and of the variables of the template.
+func newQuery(tmpl *gosq.Template) (query, error) {
+	q := query{
+		Name:  goName(tmpl.Name()),
+		Query: tmpl.Name(),
+	}
+
+	params, err := tmpl.Params()
+	if err != nil {
+		return query{}, errors.Wrapf(err, "reading params of query %s", tmpl.Name())
+	}
+	declared := make(map[string]bool)
+	for _, p := range params {
+		declared[p.Name] = true
+		q.Fields = append(q.Fields, field{Name: p.Name[1:], Type: paramTypes[p.Type]})
+	}
+
+	for _, v := range tmpl.Variables() {
+		if declared[v.Name] {
+			continue
+		}
+		typ := "interface{}"
+		if v.Predicate && !v.Piped {
+			typ = "bool"
+		}
+		q.Fields = append(q.Fields, field{Name: v.Name[1:], Type: typ})
+	}
+
+	for _, f := range q.Fields {
+		// gosq reads the args from the exported fields of the struct only.
+		if !unicode.IsUpper(rune(f.Name[0])) {
+			return query{}, errors.Errorf("variable .%s of query %s must start with an upper case letter to be a field of %sParams",
+				f.Name, tmpl.Name(), q.Name)
+		}
+	}
+	return q, nil
+}
+
+// goName returns the name of a query as an exported Go identifier, such as
+// GetProducts for get-products.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '.' || r == '-' || r == '_'
+	})
+	for i, p := range parts {
+		parts[i] = strings.ToUpper(p[:1]) + p[1:]
+	}
+	return strings.Join(parts, "")
+}
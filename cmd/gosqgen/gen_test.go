@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"queries/products.sql": {Data: []byte(`-- name: get-products
+{{ [params] .Category:string! .Limit:int=50 }}
+SELECT * FROM products
+{{ [if] .IncludeReviews [then] JOIN reviews ON reviews.product_id = products.id }}
+WHERE category = .Category AND {{ [include] filters }}
+LIMIT .Limit
+
+-- name: filters
+name LIKE {{ .Search | like }} {{ [if] .Deleted [default] false [then] [else] AND deleted_at IS NULL }}
+`)},
+	}
+
+	src, err := generate(fsys, config{pkg: "queries", pattern: "queries/*.sql", dialect: "mysql"})
+	assert.NoError(t, err)
+	assert.Equal(t, "// Code generated by gosqgen. DO NOT EDIT.\n"+`
+package queries
+
+import (
+	"embed"
+
+	"github.com/sanggonlee/gosq"
+)
+
+//go:embed queries/*.sql
+var gosqFiles embed.FS
+
+var gosqQueries = func() *gosq.TemplateSet {
+	set, err := gosq.LoadFS(gosqFiles, "queries/*.sql", gosq.WithDialect(gosq.MySQL))
+	if err != nil {
+		panic(err)
+	}
+	return set
+}()
+
+// FiltersParams are the params of the filters query.
+type FiltersParams struct {
+	Search  interface{}
+	Deleted bool
+}
+
+// BuildFilters builds the filters query, and returns it along with the
+// args bound in it.
+func BuildFilters(p FiltersParams) (string, []interface{}, error) {
+	return gosqQueries.CompileArgs("filters", p)
+}
+
+// GetProductsParams are the params of the get-products query.
+type GetProductsParams struct {
+	Category       string
+	Limit          int
+	IncludeReviews bool
+	Search         interface{}
+	Deleted        bool
+}
+
+// BuildGetProducts builds the get-products query, and returns it along with the
+// args bound in it.
+func BuildGetProducts(p GetProductsParams) (string, []interface{}, error) {
+	return gosqQueries.CompileArgs("get-products", p)
+}
+`, string(src))
+}
+
+func TestGenerate_Errors(t *testing.T) {
+	cases := []struct {
+		desc          string
+		input         string
+		dialect       string
+		expectedError string
+	}{
+		{
+			desc:          "Unknown dialect",
+			input:         "-- name: A\nSELECT 1",
+			dialect:       "oracle",
+			expectedError: `unknown dialect "oracle"`,
+		},
+		{
+			desc:          "Syntax error",
+			input:         "-- name: A\nSELECT {{ 1",
+			expectedError: "queries/a.sql:2:8",
+		},
+		{
+			desc:          "Unexported variable",
+			input:         "-- name: A\nSELECT * FROM products LIMIT .limit",
+			expectedError: "variable .limit of query A must start with an upper case letter",
+		},
+		{
+			desc:          "Same Go name",
+			input:         "-- name: get-products\nSELECT 1\n-- name: get_products\nSELECT 2",
+			expectedError: "queries get-products and get_products have the same Go name GetProducts",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			dialect := c.dialect
+			if dialect == "" {
+				dialect = "postgres"
+			}
+			fsys := fstest.MapFS{"queries/a.sql": {Data: []byte(c.input)}}
+			_, err := generate(fsys, config{pkg: "queries", pattern: "queries/*.sql", dialect: dialect})
+			assert.ErrorContains(t, err, c.expectedError)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Command gosqgen generates typed Go functions for the named queries of .sql
+// files, so a typo in a variable name is a compile error rather than a
+// runtime one.
+//
+// The queries are loaded like with gosq.LoadFS. For each of them, gosqgen
+// generates a params struct with a field for every variable the query
+// references, and a function building the query with bound args:
+//
+//	type GetProductsParams struct {
+//		Category       string
+//		IncludeReviews bool
+//		Limit          interface{}
+//	}
+//
+//	func BuildGetProducts(p GetProductsParams) (string, []interface{}, error)
+//
+// The type of a field is the one declared in the [params] header of the query
+// if any, or bool for a variable used as an [if] predicate. The other
+// variables are interface{} fields, so a value of the wrong type for them is
+// only reported when the query is built: declare them to have them typed.
+// Only the built-in functions can be called in the queries.
+// The .sql files are embedded in the package, so the pattern must be relative
+// to the package directory. Usage with go generate:
+//
+//	//go:generate go run github.com/sanggonlee/gosq/cmd/gosqgen -pkg queries queries/*.sql
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.pkg, "pkg", os.Getenv("GOPACKAGE"), "name of the package of the generated code")
+	flag.StringVar(&cfg.dialect, "dialect", "postgres", "SQL dialect: postgres, mysql, sqlite or sqlserver")
+	output := flag.String("o", "gosq_gen.go", "output file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gosqgen [flags] pattern\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || cfg.pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg.pattern = flag.Arg(0)
+
+	src, err := generate(os.DirFS("."), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gosqgen: %v\n", err)
+		os.Exit(1)
+	}
+	if err = os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "gosqgen: %v\n", err)
+		os.Exit(1)
+	}
+}
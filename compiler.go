@@ -0,0 +1,422 @@
+package gosq
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// Compiler compiles and executes query templates with a fixed set of options.
+//
+// Unless disabled with WithCache(false), a Compiler caches the parsed
+// templates, so compiling the same template many times only parses it once.
+// Up to maxCachedTemplates templates of each syntax are cached, so building
+// templates on the fly doesn't grow the cache without bound.
+//
+// The zero value is a Compiler with the default options, like New(). A
+// Compiler is safe for concurrent use.
+type Compiler struct {
+	mu    sync.Mutex
+	opts  *options
+	cache *cache
+}
+
+// maxCachedTemplates is the number of templates of each syntax a Compiler
+// caches. Past it, a random one is dropped for each new one.
+const maxCachedTemplates = 1000
+
+// cache holds the templates parsed with a particular set of options.
+type cache struct {
+	trees map[string]*ast.SyntaxTree
+	tmpls map[string]*template.Template
+}
+
+func newCache() *cache {
+	return &cache{
+		trees: make(map[string]*ast.SyntaxTree),
+		tmpls: make(map[string]*template.Template),
+	}
+}
+
+// addTree caches the syntax tree of the template, making room for it if the
+// cache is full.
+func (c *cache) addTree(tmpl string, st *ast.SyntaxTree) {
+	if len(c.trees) >= maxCachedTemplates {
+		for k := range c.trees {
+			delete(c.trees, k)
+			break
+		}
+	}
+	c.trees[tmpl] = st
+}
+
+// addTemplate caches the parsed text/template, making room for it if the
+// cache is full.
+func (c *cache) addTemplate(str string, tmpl *template.Template) {
+	if len(c.tmpls) >= maxCachedTemplates {
+		for k := range c.tmpls {
+			delete(c.tmpls, k)
+			break
+		}
+	}
+	c.tmpls[str] = tmpl
+}
+
+// New returns a Compiler configured with opts.
+func New(opts ...Option) *Compiler {
+	c := &Compiler{opts: newOptions(opts)}
+	if c.opts.cache {
+		c.cache = newCache()
+	}
+	return c
+}
+
+// Funcs adds the functions to the ones which can be called in the templates,
+// in the same way as WithFuncs. It returns the Compiler, so calls can be
+// chained.
+//
+// Templates are checked against the functions when they're parsed, so the
+// templates cached so far are dropped.
+func (c *Compiler) Funcs(funcs FuncMap) *Compiler {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+
+	merged := make(FuncMap, len(c.opts.funcs)+len(funcs))
+	for name, fn := range c.opts.funcs {
+		merged[name] = fn
+	}
+	for name, fn := range funcs {
+		merged[name] = fn
+	}
+	opts := *c.opts
+	opts.funcs = merged
+	c.opts = &opts
+
+	if c.cache != nil {
+		c.cache = newCache()
+	}
+	return c
+}
+
+// init sets the default options of a zero Compiler. c.mu must be held.
+func (c *Compiler) init() {
+	if c.opts != nil {
+		return
+	}
+	c.opts = newOptions(nil)
+	if c.opts.cache {
+		c.cache = newCache()
+	}
+}
+
+// snapshot returns the current options of the Compiler, along with the cache
+// of the templates parsed with them.
+func (c *Compiler) snapshot() (*options, *cache) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	return c.opts, c.cache
+}
+
+// Compile is the same as the package level Compile, using the options of the
+// Compiler.
+func (c *Compiler) Compile(tmpl string, args interface{}) (string, error) {
+	q, _, err := c.compile(tmpl, args, false)
+	return q, err
+}
+
+// CompileArgs is the same as the package level CompileArgs, using the options
+// of the Compiler.
+func (c *Compiler) CompileArgs(tmpl string, args interface{}) (string, []interface{}, error) {
+	return c.compile(tmpl, args, true)
+}
+
+// Parse is the same as the package level Parse, using the options of the
+// Compiler.
+func (c *Compiler) Parse(tmpl string) (*Template, error) {
+	o, cache := c.snapshot()
+	st, err := c.parse(tmpl, o, cache)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tree: st, opts: o}, nil
+}
+
+// compile evaluates the template with the args, and returns the query along
+// with the args bound in it if bind is true.
+func (c *Compiler) compile(tmpl string, args interface{}, bind bool) (string, []interface{}, error) {
+	o, cache := c.snapshot()
+	st, err := c.parse(tmpl, o, cache)
+	if err != nil {
+		return "", nil, err
+	}
+	return compileTree(st, o, args, bind, nil)
+}
+
+// compileTree evaluates the parsed template with the args, and returns the
+// query along with the args bound in it if bind is true. If predicates isn't
+// nil, it decides the branches of the [if] blocks instead of their predicates.
+// Nil args are the same as empty ones.
+func compileTree(st *ast.SyntaxTree, o *options, args interface{}, bind bool, predicates map[string]bool) (string, []interface{}, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+
+	params, err := st.Params()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "reading params")
+	}
+
+	argsLookup, err := initArgsLookupTable(args, params)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err = checkVariables(o, st.Variables(), argsLookup, argNames(args), predicates != nil); err != nil {
+		return "", nil, err
+	}
+
+	env := &ast.Env{
+		Vars:       argsLookup,
+		MissingKey: o.missingKey,
+		Funcs:      o.funcs,
+		Dialect:    o.dialect,
+		Allowlists: o.allowlists,
+		Inline:     o.inline,
+	}
+	if predicates != nil {
+		env.Predicate = func(predicate string) bool { return predicates[predicate] }
+	}
+	b := &Binder{Dialect: o.dialect}
+	if bind {
+		env.Bind = b.bind
+	}
+	q, err := st.Evaluate(env)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "evaluating template")
+	}
+
+	return o.format(q), b.Args, nil
+}
+
+// Execute is the same as the package level Execute, using the options of the
+// Compiler.
+func (c *Compiler) Execute(str string, args interface{}) (string, error) {
+	q, bound, err := c.execute(str, args, false)
+	if err != nil {
+		return "", err
+	}
+	if len(bound) > 0 {
+		return "", errors.New("template binds args, use ExecuteArgs instead")
+	}
+	return q, nil
+}
+
+// ExecuteArgs is the same as the package level ExecuteArgs, using the options
+// of the Compiler.
+func (c *Compiler) ExecuteArgs(str string, args interface{}) (string, []interface{}, error) {
+	return c.execute(str, args, true)
+}
+
+// execute executes the text/template, and returns the query along with the
+// args bound in it. With auto-escaping, values are bound as args if bind is
+// true, or inlined as literals otherwise.
+func (c *Compiler) execute(str string, args interface{}, bind bool) (string, []interface{}, error) {
+	o, cache := c.snapshot()
+	tmpl, err := c.parseTemplate(str, o, cache)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if o.checkUnusedArgs {
+		used := make(map[string]bool)
+		for _, t := range tmpl.Templates() {
+			templateFields(t.Root, used)
+		}
+		if err = checkUnusedArgs(argNames(args), used); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// The parsed template is shared, so bind the functions collecting the
+	// args of this execution to a clone of it.
+	tmpl, err = tmpl.Clone()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "cloning template")
+	}
+	b := &Binder{Dialect: o.dialect, inline: !bind}
+	tmpl.Funcs(b.FuncMap()).Funcs(template.FuncMap(o.funcs)).Funcs(b.escapers())
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, args); err != nil {
+		return "", nil, errors.Wrap(err, "executing template")
+	}
+
+	return o.format(buf.String()), b.Args, nil
+}
+
+// parse returns the syntax tree of the template, from the cache if possible.
+func (c *Compiler) parse(tmpl string, o *options, cache *cache) (*ast.SyntaxTree, error) {
+	if cache != nil {
+		c.mu.Lock()
+		st, ok := cache.trees[tmpl]
+		c.mu.Unlock()
+		if ok {
+			return st, nil
+		}
+	}
+
+	st, err := parseTree(tmpl, o)
+	if err != nil {
+		return nil, err
+	}
+
+	defines, err := st.Defines()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading defines")
+	}
+	if err = st.ResolveIncludes(defines); err != nil {
+		return nil, errors.Wrap(err, "resolving includes")
+	}
+
+	if cache != nil {
+		c.mu.Lock()
+		cache.addTree(tmpl, st)
+		c.mu.Unlock()
+	}
+	return st, nil
+}
+
+// parseTree parses the template into a syntax tree, and checks the functions
+// called in it. The [include] blocks are left unresolved.
+func parseTree(tmpl string, o *options) (*ast.SyntaxTree, error) {
+	tt, err := ast.BuildTokenTree(tmpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "tokenizing template")
+	}
+
+	node, err := tt.Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, "building AST")
+	}
+	// Parsing a TokenTree always results in a SyntaxTree.
+	st := node.(*ast.SyntaxTree)
+
+	if err = st.CheckFuncs(o.funcs); err != nil {
+		return nil, errors.Wrap(err, "checking functions")
+	}
+	return st, nil
+}
+
+// parseTemplate returns the parsed text/template, from the cache if possible.
+func (c *Compiler) parseTemplate(str string, o *options, cache *cache) (*template.Template, error) {
+	if cache != nil {
+		c.mu.Lock()
+		tmpl, ok := cache.tmpls[str]
+		c.mu.Unlock()
+		if ok {
+			return tmpl, nil
+		}
+	}
+
+	tmpl, err := template.New("gosq").
+		Funcs((&Binder{}).FuncMap()).
+		Funcs(template.FuncMap(o.funcs)).
+		Option(o.templateOption()).
+		Parse(str)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing template")
+	}
+
+	if o.autoEscape {
+		if err = escapeTemplate(tmpl, o.dialect); err != nil {
+			return nil, errors.Wrap(err, "escaping template")
+		}
+	}
+
+	if cache != nil {
+		c.mu.Lock()
+		cache.addTemplate(str, tmpl)
+		c.mu.Unlock()
+	}
+	return tmpl, nil
+}
+
+// checkVariables checks the variables referenced anywhere in the template
+// against the args, according to the missing key policy and the unused args
+// check. Unlike the evaluation, it also covers the branches which aren't taken.
+// If the branches of the [if] blocks are decided, only the variables of the
+// branches taken are needed, which the evaluation checks on its own.
+//
+// names are the names of the args given, which the unused args check covers.
+// The parameters which take their default value aren't among them.
+func checkVariables(o *options, vars []ast.Variable, argsLookup map[string]interface{}, names []string, decided bool) error {
+	used := make(map[string]bool)
+	for _, v := range vars {
+		if _, ok := argsLookup[v.Name]; !ok && !v.HasDefault && !decided && o.missingKey == MissingKeyError {
+			return fmt.Errorf("no value given for variable %s", v.Name)
+		}
+		used[strings.TrimPrefix(v.Name, ".")] = true
+	}
+
+	if !o.checkUnusedArgs {
+		return nil
+	}
+	return checkUnusedArgs(names, used)
+}
+
+// templateFields marks the names of the fields of dot referenced in the
+// text/template node as used.
+func templateFields(node parse.Node, used map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			templateFields(child, used)
+		}
+	case *parse.ActionNode:
+		templateFields(n.Pipe, used)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			templateFields(cmd, used)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			templateFields(arg, used)
+		}
+	case *parse.ChainNode:
+		templateFields(n.Node, used)
+	case *parse.FieldNode:
+		used[n.Ident[0]] = true
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			used[n.Ident[1]] = true
+		}
+	case *parse.IfNode:
+		templateFields(n.Pipe, used)
+		templateFields(n.List, used)
+		templateFields(n.ElseList, used)
+	case *parse.RangeNode:
+		templateFields(n.Pipe, used)
+		templateFields(n.List, used)
+		templateFields(n.ElseList, used)
+	case *parse.WithNode:
+		templateFields(n.Pipe, used)
+		templateFields(n.List, used)
+		templateFields(n.ElseList, used)
+	case *parse.TemplateNode:
+		templateFields(n.Pipe, used)
+	}
+}
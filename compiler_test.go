@@ -0,0 +1,243 @@
+package gosq_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/sanggonlee/gosq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompiler_Compile(t *testing.T) {
+	inputTemplate := `
+		SELECT
+			products.*
+			{{ [if] .IncludeReviews [then] ,json_agg(reviews) AS reviews }}
+		FROM products
+		LIMIT .Limit
+	`
+	cases := []struct {
+		desc     string
+		args     interface{}
+		expected string
+	}{
+		{
+			desc: "Truthy",
+			args: map[string]interface{}{
+				"IncludeReviews": true,
+				"Limit":          10,
+			},
+			expected: `SELECT products.* ,json_agg(reviews) AS reviews FROM products LIMIT 10`,
+		},
+		{
+			desc: "Falsey",
+			args: map[string]interface{}{
+				"IncludeReviews": false,
+				"Limit":          20,
+			},
+			expected: `SELECT products.* FROM products LIMIT 20`,
+		},
+	}
+
+	for _, opts := range [][]gosq.Option{nil, {gosq.WithCache(false)}} {
+		c := gosq.New(opts...)
+		// Compile each case twice, to make sure the cached template is reusable.
+		for i := 0; i < 2; i++ {
+			for _, tc := range cases {
+				t.Run(tc.desc, func(t *testing.T) {
+					result, err := c.Compile(inputTemplate, tc.args)
+					assert.NoError(t, err)
+					assert.Equal(t, tc.expected, result)
+				})
+			}
+		}
+	}
+}
+
+func TestCompiler_MissingKey(t *testing.T) {
+	_, err := gosq.New().Compile(`SELECT a .b FROM t`, map[string]interface{}{})
+	assert.EqualError(t, err, "no value given for variable .b")
+
+	result, err := gosq.Compile(`SELECT a .b FROM t`, map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT a .b FROM t`, result)
+}
+
+func TestCompiler_ZeroValue(t *testing.T) {
+	var c gosq.Compiler
+	result, err := c.Compile(`SELECT * FROM products LIMIT .Limit`, map[string]interface{}{"Limit": 10})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products LIMIT 10`, result)
+
+	_, err = c.Compile(`SELECT * FROM products LIMIT .Limit`, nil)
+	assert.EqualError(t, err, "no value given for variable .Limit")
+
+	var other gosq.Compiler
+	result, err = other.Funcs(gosq.FuncMap{"double": func(n int) int { return 2 * n }}).
+		Compile(`LIMIT {{ .Limit | double }}`, map[string]interface{}{"Limit": 10})
+	assert.NoError(t, err)
+	assert.Equal(t, `LIMIT 20`, result)
+}
+
+func TestCompiler_ManyTemplates(t *testing.T) {
+	// More templates than the cache holds.
+	c := gosq.New()
+	for i := 0; i < 1500; i++ {
+		result, err := c.Compile(fmt.Sprintf(`SELECT %d LIMIT .Limit`, i%1200), map[string]interface{}{"Limit": i})
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf(`SELECT %d LIMIT %d`, i%1200, i), result)
+	}
+}
+
+func TestCompiler_Blocks(t *testing.T) {
+	// The blocks are copies, so modifying them doesn't affect the cached
+	// template.
+	c := gosq.New()
+	tmpl, err := c.Parse(`SELECT * {{ [if] .A [then] WHERE a }}`)
+	assert.NoError(t, err)
+	tmpl.Blocks()[0].Then.Nodes = nil
+
+	result, err := c.Compile(`SELECT * {{ [if] .A [then] WHERE a }}`, map[string]interface{}{"A": true})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * WHERE a`, result)
+}
+
+func TestCompiler_Concurrent(t *testing.T) {
+	c := gosq.New()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(includeReviews bool) {
+			defer wg.Done()
+			result, err := c.Compile(
+				`SELECT * {{ [if] .IncludeReviews [then] ,reviews [else] ,NULL }} FROM products`,
+				map[string]interface{}{"IncludeReviews": includeReviews},
+			)
+			assert.NoError(t, err)
+			if includeReviews {
+				assert.Equal(t, `SELECT * ,reviews FROM products`, result)
+			} else {
+				assert.Equal(t, `SELECT * ,NULL FROM products`, result)
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
+
+func TestCompiler_Funcs(t *testing.T) {
+	inputTemplate := `
+		SELECT * FROM products
+		WHERE {{ .Tenant | tenantScope }}
+		{{ [if] .Role | isAdmin [then] [else] AND deleted_at IS NULL }}
+	`
+	args := map[string]interface{}{
+		"Tenant": 42,
+		"Role":   "viewer",
+	}
+
+	c := gosq.New()
+	_, err := c.Compile(inputTemplate, args)
+	assert.ErrorContains(t, err, "function tenantScope is not defined")
+
+	c.Funcs(gosq.FuncMap{
+		"tenantScope": func(id int) string { return fmt.Sprintf("tenant_id = %d", id) },
+		"isAdmin":     func(role string) bool { return role == "admin" },
+	})
+	result, err := c.Compile(inputTemplate, args)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products WHERE tenant_id = 42 AND deleted_at IS NULL`, result)
+
+	_, err = gosq.New().Funcs(gosq.FuncMap{
+		"tenantScope": func(id int, table string) string { return table },
+		"isAdmin":     func(role string) string { return role },
+	}).Compile(inputTemplate, args)
+	assert.ErrorContains(t, err, "wrong number of args for tenantScope")
+
+	_, err = gosq.New().Funcs(gosq.FuncMap{
+		"tenantScope": func(id int) string { return "" },
+		"isAdmin":     func(role string) string { return role },
+	}).Compile(inputTemplate, args)
+	assert.ErrorContains(t, err, "predicate must be a boolean expression")
+}
+
+func TestCompiler_Execute(t *testing.T) {
+	inputTemplate := `
+		SELECT
+			products.*
+			{{if .IncludeReviews}} ,json_agg(reviews) AS reviews {{end}}
+		FROM products
+		LIMIT {{.Limit}}
+	`
+	cases := []struct {
+		desc          string
+		inputArgs     interface{}
+		inputOptions  []gosq.Option
+		expected      string
+		expectedError string
+	}{
+		{
+			desc: "Missing key is an error by default",
+			inputArgs: map[string]interface{}{
+				"IncludeReviews": true,
+			},
+			expectedError: `map has no entry for key "Limit"`,
+		},
+		{
+			desc: "Missing key is zero value",
+			inputArgs: map[string]int{
+				"IncludeReviews": 1,
+			},
+			inputOptions: []gosq.Option{gosq.WithMissingKey(gosq.MissingKeyZero)},
+			expected:     "\n\t\tSELECT\n\t\t\tproducts.*\n\t\t\t ,json_agg(reviews) AS reviews \n\t\tFROM products\n\t\tLIMIT 0\n\t",
+		},
+		{
+			desc: "Compact whitespace",
+			inputArgs: map[string]interface{}{
+				"IncludeReviews": false,
+				"Limit":          10,
+			},
+			inputOptions: []gosq.Option{gosq.WithCompactWhitespace()},
+			expected:     `SELECT products.* FROM products LIMIT 10`,
+		},
+		{
+			desc: "Unused args are reported",
+			inputArgs: struct {
+				IncludeReviews bool
+				Limit          int
+				Offset         int
+			}{},
+			inputOptions:  []gosq.Option{gosq.WithUnusedArgsCheck()},
+			expectedError: "unused args: Offset",
+		},
+		{
+			desc: "All args are used",
+			inputArgs: struct {
+				IncludeReviews bool
+				Limit          int
+			}{
+				Limit: 10,
+			},
+			inputOptions: []gosq.Option{gosq.WithUnusedArgsCheck(), gosq.WithCompactWhitespace()},
+			expected:     `SELECT products.* FROM products LIMIT 10`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.New(c.inputOptions...).Execute(inputTemplate, c.inputArgs)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+}
+
+func BenchmarkCompiler_Compile(b *testing.B) {
+	c := gosq.New()
+	for n := 0; n < b.N; n++ {
+		_, _ = c.Compile(benchmarkInputTmpl, benchmarkInputArgs)
+	}
+}
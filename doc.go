@@ -20,11 +20,25 @@ It provides a very simple syntax to inject arbitrary conditional query piece.
    IncludeReviews: true,
  })
 
+The expressions between {{ and }} start with a keyword:
+ - [if] predicate [then] clause [else] clause: a conditional clause, the [else] being optional.
+   A predicate can have a default, as in [if] .Desc [default] true [then].
+ - [default] .Variable value: the value of the variable, or the default if it's missing or zero.
+ - [params] .Name:type! .Name:type=value: declares the parameters of the template, with their
+   types, whether they're required, and their defaults.
+ - [ident] .Variable: the value of the variable quoted as an identifier of the dialect.
+ - [oneof] .Variable "value"...: the value of the variable, which must be one of the listed ones.
+ - [dir] .Variable: ASC, DESC, or nothing.
+ - [raw] .Variable: the value of the variable verbatim, even when the other values are bound.
+ - [define] name clause and [include] name: a partial, defined once and included anywhere.
+ - .Variable | function args...: a pipeline, passing the value through the functions in turn.
+
+See Compile for the details of each of them.
 
 Limitations:
- - The predicate (expression between [if] and [then]) must be a one word: either true, false or
-   a parameter that evaluates to a boolean. There's no support to evaluate an arbitrary predicate
-   expression yet.
+ - The predicate (expression between [if] and [then]) must be either a single word, true,
+   false or a parameter that evaluates to a boolean, or a pipeline resulting in a boolean,
+   such as .Role | isAdmin. There's no support for comparisons or boolean operators yet.
  - gosq does not validate nor executes the query itself. The only thing it does is
    build the query in string out of a template.
 
@@ -0,0 +1,322 @@
+package gosq
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// sqlState is the lexical state of the query at some point of the template.
+type sqlState int
+
+const (
+	stateValue sqlState = iota
+	stateString
+	stateQuotedIdent
+	stateDollarQuoted
+	stateLineComment
+	stateBlockComment
+)
+
+func (s sqlState) String() string {
+	switch s {
+	case stateString:
+		return "a string literal"
+	case stateQuotedIdent:
+		return "a quoted identifier"
+	case stateDollarQuoted:
+		return "a dollar-quoted string"
+	case stateLineComment, stateBlockComment:
+		return "a comment"
+	}
+	return "a value position"
+}
+
+// sqlContext is the SQL context in which the output of an action ends up.
+type sqlContext struct {
+	state sqlState
+	// delim is the closing delimiter of a string literal or quoted identifier.
+	delim byte
+	// backslash is true in a string literal where a backslash escapes the
+	// next character, which is any one of MySQL, or a Postgres E'...' string.
+	backslash bool
+	// tag is the tag of a Postgres dollar-quoted string, including the
+	// dollar signs, such as $$ or $body$.
+	tag string
+	// ident is true in a value position where an identifier is expected.
+	ident bool
+}
+
+// identRegex matches the end of a text after which an identifier is expected.
+var identRegex = regexp.MustCompile(`(?i)(?:\b(?:FROM|JOIN|UPDATE|INTO|TABLE|BY)|\.)$`)
+
+// dollarTagRegex matches the opening tag of a Postgres dollar-quoted string.
+var dollarTagRegex = regexp.MustCompile(`^\$(?:[A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// Names of the functions appended to the actions by the escaper. They're
+// bound for each execution by Binder.escapers.
+const (
+	escapeValueFunc       = "_gosq_value"
+	escapeIdentFunc       = "_gosq_ident"
+	escapeStringFunc      = "_gosq_string"
+	escapeBackslashFunc   = "_gosq_backslash_string"
+	escapeQuotedIdentFunc = "_gosq_quoted_ident"
+)
+
+// safeFuncs are the functions whose output is safe in a value position.
+var safeFuncs = map[string]bool{
+	"bind":    true,
+	"in":      true,
+	"ident":   true,
+	"literal": true,
+}
+
+// escaper rewrites the actions of a text/template to escape their output
+// according to their SQL context.
+type escaper struct {
+	dialect Dialect
+	// tree is the tree being escaped, to locate errors.
+	tree *parse.Tree
+	// called are the names of the templates called with {{template}}.
+	called map[string]bool
+}
+
+// escapeTemplate escapes the actions of all templates associated with tmpl.
+// Each template starts in a value position, and a template called by another
+// one must also end in a value position.
+func escapeTemplate(tmpl *template.Template, d Dialect) error {
+	e := &escaper{dialect: d, called: make(map[string]bool)}
+	ends := make(map[string]sqlContext)
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		e.tree = t.Tree
+		c, err := e.escapeList(t.Tree.Root, sqlContext{})
+		if err != nil {
+			return err
+		}
+		ends[t.Name()] = c
+	}
+	for name := range e.called {
+		if c, ok := ends[name]; ok && c.state != stateValue {
+			return errors.Errorf("template %s ends inside %s", name, c.state)
+		}
+	}
+	return nil
+}
+
+func (e *escaper) escapeList(list *parse.ListNode, c sqlContext) (sqlContext, error) {
+	if list == nil {
+		return c, nil
+	}
+	var err error
+	for _, n := range list.Nodes {
+		if c, err = e.escapeNode(n, c); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+func (e *escaper) escapeNode(node parse.Node, c sqlContext) (sqlContext, error) {
+	switch n := node.(type) {
+	case *parse.TextNode:
+		return e.scanText(c, string(n.Text)), nil
+	case *parse.ActionNode:
+		return e.escapeAction(n, c)
+	case *parse.IfNode:
+		return e.escapeBranches(n, &n.BranchNode, c)
+	case *parse.WithNode:
+		return e.escapeBranches(n, &n.BranchNode, c)
+	case *parse.RangeNode:
+		body, err := e.escapeList(n.List, c)
+		if err != nil {
+			return c, err
+		}
+		if _, err = e.join(n, c, body); err != nil {
+			return c, err
+		}
+		return e.escapeBranches(n, &n.BranchNode, c)
+	case *parse.TemplateNode:
+		if c.state != stateValue {
+			return c, e.errorf(n, "{{template}} call inside %s", c.state)
+		}
+		e.called[n.Name] = true
+		return sqlContext{}, nil
+	}
+	return c, nil
+}
+
+// escapeBranches escapes both branches of the node, which must end in the
+// same context.
+func (e *escaper) escapeBranches(n parse.Node, b *parse.BranchNode, c sqlContext) (sqlContext, error) {
+	then, err := e.escapeList(b.List, c)
+	if err != nil {
+		return c, err
+	}
+	otherwise, err := e.escapeList(b.ElseList, c)
+	if err != nil {
+		return c, err
+	}
+	return e.join(n, then, otherwise)
+}
+
+// join returns the context after either of two branches, or an error if they
+// end in different contexts.
+func (e *escaper) join(n parse.Node, a, b sqlContext) (sqlContext, error) {
+	if a.state != b.state || a.delim != b.delim || a.backslash != b.backslash || a.tag != b.tag {
+		return a, e.errorf(n, "branches end inside %s and %s", a.state, b.state)
+	}
+	a.ident = a.ident && b.ident
+	return a, nil
+}
+
+// escapeAction appends the escaping function for the context to the pipeline
+// of the action.
+func (e *escaper) escapeAction(n *parse.ActionNode, c sqlContext) (sqlContext, error) {
+	// Declaring a variable doesn't output anything.
+	if len(n.Pipe.Decl) > 0 {
+		return c, nil
+	}
+
+	var cmd []parse.Node
+	switch c.state {
+	case stateValue:
+		if isSafePipe(n.Pipe) {
+			return sqlContext{}, nil
+		}
+		if c.ident {
+			cmd = []parse.Node{parse.NewIdentifier(escapeIdentFunc)}
+		} else {
+			cmd = []parse.Node{parse.NewIdentifier(escapeValueFunc)}
+		}
+	case stateString:
+		if c.backslash {
+			cmd = []parse.Node{parse.NewIdentifier(escapeBackslashFunc), newString(c.delim)}
+		} else {
+			cmd = []parse.Node{parse.NewIdentifier(escapeStringFunc), newString(c.delim)}
+		}
+	case stateQuotedIdent:
+		cmd = []parse.Node{parse.NewIdentifier(escapeQuotedIdentFunc), newString(c.delim)}
+	default:
+		return c, e.errorf(n, "action inside %s", c.state)
+	}
+
+	for _, arg := range cmd {
+		if id, ok := arg.(*parse.IdentifierNode); ok {
+			id.SetTree(e.tree).SetPos(n.Pos)
+		}
+	}
+	n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      n.Pos,
+		Args:     cmd,
+	})
+	c.ident = false
+	return c, nil
+}
+
+// isSafePipe checks if the pipeline ends with a function whose output is safe
+// in a value position.
+func isSafePipe(p *parse.PipeNode) bool {
+	if len(p.Cmds) == 0 {
+		return false
+	}
+	last := p.Cmds[len(p.Cmds)-1]
+	id, ok := last.Args[0].(*parse.IdentifierNode)
+	return ok && safeFuncs[id.Ident]
+}
+
+func newString(delim byte) *parse.StringNode {
+	s := string(delim)
+	return &parse.StringNode{NodeType: parse.NodeString, Quoted: strconv.Quote(s), Text: s}
+}
+
+// scanText returns the context at the end of the text, starting from c.
+func (e *escaper) scanText(c sqlContext, text string) sqlContext {
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch c.state {
+		case stateValue:
+			switch {
+			case ch == '\'':
+				c.state, c.delim = stateString, '\''
+				c.backslash = e.dialect == MySQL || (e.dialect == Postgres && isEscapeStringPrefix(text[:i]))
+			case ch == '"' && e.dialect == MySQL:
+				c.state, c.delim, c.backslash = stateString, '"', true
+			case ch == '"':
+				c.state, c.delim = stateQuotedIdent, '"'
+			case ch == '`' && e.dialect == MySQL:
+				c.state, c.delim = stateQuotedIdent, '`'
+			case ch == '[' && e.dialect == SQLServer:
+				c.state, c.delim = stateQuotedIdent, ']'
+			case ch == '$' && e.dialect == Postgres && (i == 0 || !isIdentChar(text[i-1])):
+				if tag := dollarTagRegex.FindString(text[i:]); tag != "" {
+					c.state, c.tag = stateDollarQuoted, tag
+					i += len(tag) - 1
+				}
+			case ch == '#' && e.dialect == MySQL:
+				c.state = stateLineComment
+			case strings.HasPrefix(text[i:], "--"):
+				c.state = stateLineComment
+				i++
+			case strings.HasPrefix(text[i:], "/*"):
+				c.state = stateBlockComment
+				i++
+			}
+		case stateString, stateQuotedIdent:
+			if ch == '\\' && c.backslash {
+				i++
+			} else if ch == c.delim {
+				// A doubled delimiter closes and reopens the literal, which
+				// ends up in the same state.
+				c.state, c.delim, c.backslash = stateValue, 0, false
+			}
+		case stateDollarQuoted:
+			end := strings.Index(text[i:], c.tag)
+			if end < 0 {
+				i = len(text)
+				break
+			}
+			i += end + len(c.tag) - 1
+			c.state, c.tag = stateValue, ""
+		case stateLineComment:
+			if ch == '\n' {
+				c.state = stateValue
+			}
+		case stateBlockComment:
+			if strings.HasPrefix(text[i:], "*/") {
+				c.state = stateValue
+				i++
+			}
+		}
+	}
+
+	if c.state == stateValue && strings.TrimSpace(text) != "" {
+		c.ident = identRegex.MatchString(strings.TrimRightFunc(text, unicode.IsSpace))
+	}
+	return c
+}
+
+// isEscapeStringPrefix checks if the text before a quote ends with the E
+// prefix of a Postgres escape string, such as E'\n'.
+func isEscapeStringPrefix(text string) bool {
+	n := len(text)
+	return n > 0 && (text[n-1] == 'E' || text[n-1] == 'e') && (n == 1 || !isIdentChar(text[n-2]))
+}
+
+// isIdentChar checks if ch can be part of an unquoted identifier.
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func (e *escaper) errorf(n parse.Node, format string, args ...interface{}) error {
+	location, _ := e.tree.ErrorContext(n)
+	return errors.Errorf("%s: "+format, append([]interface{}{location}, args...)...)
+}
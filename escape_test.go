@@ -0,0 +1,158 @@
+package gosq_test
+
+import (
+	"testing"
+
+	"github.com/sanggonlee/gosq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteArgs_AutoEscape(t *testing.T) {
+	args := map[string]interface{}{
+		"Table":  "products",
+		"Column": "name",
+		"Name":   "'; DROP TABLE products; --",
+		"Slash":  `\'; DROP TABLE products; --`,
+		"IDs":    []int{1, 2},
+		"NoIDs":  []int{},
+		"Limit":  10,
+		"Filter": gosq.Raw("deleted_at IS NULL"),
+	}
+	cases := []struct {
+		desc          string
+		input         string
+		inputOptions  []gosq.Option
+		expected      string
+		expectedArgs  []interface{}
+		expectedError string
+	}{
+		{
+			desc:         "Values are bound",
+			input:        `SELECT * FROM products WHERE name = {{ .Name }} AND id IN {{ .IDs }} LIMIT {{ .Limit }}`,
+			expected:     `SELECT * FROM products WHERE name = $1 AND id IN ($2, $3) LIMIT $4`,
+			expectedArgs: []interface{}{"'; DROP TABLE products; --", 1, 2, 10},
+		},
+		{
+			desc:     "Identifiers are quoted",
+			input:    `SELECT p.{{ .Column }} FROM {{ .Table }} p ORDER BY {{ .Column }}`,
+			expected: `SELECT p."name" FROM "products" p ORDER BY "name"`,
+		},
+		{
+			desc:     "String literal",
+			input:    `SELECT * FROM products WHERE name LIKE '%{{ .Name }}%'`,
+			expected: `SELECT * FROM products WHERE name LIKE '%''; DROP TABLE products; --%'`,
+		},
+		{
+			desc:         "MySQL string literal",
+			input:        `SELECT * FROM products WHERE name = "{{ .Name }}" AND id = {{ .Limit }}`,
+			inputOptions: []gosq.Option{gosq.WithDialect(gosq.MySQL)},
+			expected:     `SELECT * FROM products WHERE name = "'; DROP TABLE products; --" AND id = ?`,
+			expectedArgs: []interface{}{10},
+		},
+		{
+			desc:     "Postgres escape string literal",
+			input:    `SELECT * FROM products WHERE name = E'{{ .Slash }}\n' OR name = e'{{ .Name }}'`,
+			expected: `SELECT * FROM products WHERE name = E'\\''; DROP TABLE products; --\n' OR name = e'''; DROP TABLE products; --'`,
+		},
+		{
+			desc:     "Identifier ending with E before a string literal",
+			input:    `SELECT * FROM products WHERE name = type'{{ .Slash }}'`,
+			expected: `SELECT * FROM products WHERE name = type'\''; DROP TABLE products; --'`,
+		},
+		{
+			desc:         "Dollar-quoted string",
+			input:        `SELECT $$it's$$, $body$ $$ ' $body$ FROM products WHERE id = {{ .Limit }}`,
+			expected:     `SELECT $$it's$$, $body$ $$ ' $body$ FROM products WHERE id = $1`,
+			expectedArgs: []interface{}{10},
+		},
+		{
+			desc:          "Action inside a dollar-quoted string",
+			input:         `SELECT * FROM products WHERE name = $${{ .Name }}$$`,
+			expectedError: "action inside a dollar-quoted string",
+		},
+		{
+			desc:          "Action inside a tagged dollar-quoted string",
+			input:         `SELECT * FROM products WHERE name = $q$ it's {{ .Name }}$q$`,
+			expectedError: "action inside a dollar-quoted string",
+		},
+		{
+			desc:     "Quoted identifier",
+			input:    `SELECT "{{ .Name }}" FROM products`,
+			expected: `SELECT "'; DROP TABLE products; --" FROM products`,
+		},
+		{
+			desc:         "Explicit functions are left as-is",
+			input:        `SELECT * FROM {{ ident .Table }} WHERE id = {{ .Limit | bind }} {{ $n := .Name }}`,
+			expected:     `SELECT * FROM "products" WHERE id = $1`,
+			expectedArgs: []interface{}{10},
+		},
+		{
+			desc:         "Branches",
+			input:        `SELECT * FROM products {{ if .Limit }} WHERE name = {{ .Name }} {{ else }} WHERE TRUE {{ end }} LIMIT {{ .Limit }}`,
+			expected:     `SELECT * FROM products WHERE name = $1 LIMIT $2`,
+			expectedArgs: []interface{}{"'; DROP TABLE products; --", 10},
+		},
+		{
+			desc:         "Raw values are inlined",
+			input:        `SELECT * FROM products WHERE {{ .Filter }} LIMIT {{ .Limit }}`,
+			expected:     `SELECT * FROM products WHERE deleted_at IS NULL LIMIT $1`,
+			expectedArgs: []interface{}{10},
+		},
+		{
+			desc:          "Action inside a comment",
+			input:         `SELECT * FROM products -- {{ .Name }}`,
+			expectedError: "action inside a comment",
+		},
+		{
+			desc:          "Action inside a block comment",
+			input:         `SELECT * FROM products /* {{ .Name }} */`,
+			expectedError: "action inside a comment",
+		},
+		{
+			desc:          "Branches end in different contexts",
+			input:         `SELECT * FROM products WHERE name = {{ if .Limit }}'{{ end }}x'`,
+			expectedError: "branches end inside a string literal and a value position",
+		},
+		{
+			desc:          "Empty slice",
+			input:         `SELECT * FROM products WHERE id NOT IN {{ .NoIDs }}`,
+			expectedError: "empty slice can't be a list",
+		},
+		{
+			desc:          "Invalid identifier",
+			input:         `SELECT * FROM {{ .Name }}`,
+			expectedError: "invalid identifier",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			opts := append([]gosq.Option{gosq.WithAutoEscape(), gosq.WithCompactWhitespace()}, c.inputOptions...)
+			result, boundArgs, err := gosq.ExecuteArgs(c.input, args, opts...)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+			assert.Equal(t, c.expectedArgs, boundArgs)
+		})
+	}
+}
+
+func TestExecute_AutoEscape(t *testing.T) {
+	result, err := gosq.Execute(
+		`SELECT * FROM {{ .Table }} WHERE name = {{ .Name }} AND id IN {{ .IDs }}`,
+		map[string]interface{}{
+			"Table": "products",
+			"Name":  "it's",
+			"IDs":   []int{1, 2},
+		},
+		gosq.WithAutoEscape(),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM "products" WHERE name = 'it''s' AND id IN (1, 2)`, result)
+
+	_, err = gosq.Execute(`SELECT * FROM products WHERE id NOT IN {{ .IDs }}`,
+		map[string]interface{}{"IDs": []int{}}, gosq.WithAutoEscape())
+	assert.ErrorContains(t, err, "empty slice can't be a list")
+}
@@ -0,0 +1,317 @@
+// Package format formats templates of the gosq syntax in a canonical layout.
+package format
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// role is the role of a word of the template in the layout.
+type role int
+
+const (
+	// roleText is a word of the SQL text, or of the clause of an expression.
+	roleText role = iota
+	// roleOpen is the {{ opening an expression.
+	roleOpen
+	// roleHeader is a word of an expression before its clause, such as the
+	// keyword and predicate of an [if] block up to [then].
+	roleHeader
+	// roleElse is the [else] of an [if] block.
+	roleElse
+	// roleClose is the }} closing an expression.
+	roleClose
+)
+
+// headerMode tells where the header of an expression ends.
+type headerMode int
+
+const (
+	// headerFirst is the mode before the first word of the expression.
+	headerFirst headerMode = iota
+	// headerThen ends the header with [then].
+	headerThen
+	// headerWords ends the header after a number of words.
+	headerWords
+	// headerAll makes the whole expression a header.
+	headerAll
+	// headerDone is the mode after the header.
+	headerDone
+)
+
+// headerKeywords are the keywords of the expressions which are headers only.
+var headerKeywords = map[string]bool{
+	"[default]": true,
+	"[ident]":   true,
+	"[raw]":     true,
+	"[oneof]":   true,
+	"[dir]":     true,
+	"[include]": true,
+}
+
+type word struct {
+	ast.Token
+	role role
+	// gap is the whitespace before the word.
+	gap string
+	// expr is the index of the innermost expression the word is part of, or
+	// -1 if it's not part of any. The {{ of an expression is part of the
+	// enclosing one.
+	expr int
+	// opens is the index of the expression opened by a {{.
+	opens int
+}
+
+type expr struct {
+	mode  headerMode
+	words int
+	isIf  bool
+	// minIndent is the length of the smallest indentation of the lines of
+	// text of the clause, or -1 if the clause has none.
+	minIndent int
+	// indent is the indentation of the formatted line the expression opens
+	// on.
+	indent string
+}
+
+// Source formats the template src in the canonical layout:
+//   - The words of an expression up to its clause, such as
+//     {{ [if] .Predicate [then], are separated by single spaces, and so are
+//     {{, }} and [else] from the words around them on the same line.
+//   - The lines of a clause are indented one level deeper than the line the
+//     expression opens on. Lines of SQL text keep their indentation relative
+//     to each other, and a line starting with }} or [else] is indented as the
+//     line the expression opens on. A level is a tab if the template is
+//     indented with tabs, or two spaces otherwise.
+//   - Trailing whitespace is removed.
+//
+// The SQL text outside the expressions, and the words and line breaks of the
+// clauses, are kept as they are. It returns an error if src isn't a valid
+// template.
+func Source(src []byte) ([]byte, error) {
+	s := string(src)
+	original, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	words, exprs := layout(s)
+	if len(words) == 0 {
+		return src, nil
+	}
+	out := render(s, words, exprs)
+
+	// Formatting only changes whitespace, so the template must stay the same.
+	formatted, err := parse(out)
+	if err != nil || ast.Print(formatted) != ast.Print(original) {
+		return nil, errors.New("formatting changed the template")
+	}
+	return []byte(out), nil
+}
+
+func parse(s string) (ast.LanguageNode, error) {
+	tt, err := ast.BuildTokenTree(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "building token tree")
+	}
+	node, err := tt.Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing template")
+	}
+	return node, nil
+}
+
+// layout splits the template into words, along with the roles of the words
+// and the expressions they're part of.
+func layout(s string) ([]word, []expr) {
+	tokens := ast.Tokenize(s)
+	words := make([]word, len(tokens))
+	var (
+		exprs []expr
+		stack []int
+		end   int
+	)
+	for i, tok := range tokens {
+		words[i] = word{Token: tok, gap: s[end:tok.Pos.Offset], expr: -1}
+		end = tok.Pos.Offset + len(tok.Text)
+		if len(stack) > 0 {
+			words[i].expr = stack[len(stack)-1]
+		}
+
+		switch tok.Text {
+		case "{{":
+			words[i].role = roleOpen
+			words[i].opens = len(exprs)
+			stack = append(stack, len(exprs))
+			exprs = append(exprs, expr{minIndent: -1})
+			continue
+		case "}}":
+			words[i].role = roleClose
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		if len(stack) == 0 {
+			continue
+		}
+
+		e := &exprs[stack[len(stack)-1]]
+		words[i].role = roleText
+		switch e.mode {
+		case headerFirst:
+			switch {
+			case tok.Text == "[if]":
+				e.mode, e.isIf = headerThen, true
+			case tok.Text == "[define]":
+				e.mode, e.words = headerWords, 1
+			case tok.Text == "[params]":
+				e.mode = headerDone
+			case headerKeywords[tok.Text] || isPipeline(tokens[i:]):
+				e.mode = headerAll
+			default:
+				e.mode = headerDone
+			}
+			if e.mode != headerDone || tok.Text == "[params]" {
+				words[i].role = roleHeader
+			}
+		case headerThen:
+			words[i].role = roleHeader
+			if tok.Text == "[then]" {
+				e.mode = headerDone
+			}
+		case headerWords:
+			words[i].role = roleHeader
+			if e.words--; e.words == 0 {
+				e.mode = headerDone
+			}
+		case headerAll:
+			words[i].role = roleHeader
+		case headerDone:
+			if e.isIf && tok.Text == "[else]" {
+				words[i].role = roleElse
+			}
+		}
+	}
+
+	for _, w := range words {
+		if w.expr < 0 || w.role != roleText || !strings.Contains(w.gap, "\n") {
+			continue
+		}
+		e := &exprs[w.expr]
+		if n := len(indentOf(w.gap)); e.minIndent < 0 || n < e.minIndent {
+			e.minIndent = n
+		}
+	}
+	return words, exprs
+}
+
+// isPipeline checks if the expression starting with the tokens has a pipe
+// outside of the expressions nested in it.
+func isPipeline(tokens []ast.Token) bool {
+	var depth int
+	for _, tok := range tokens {
+		switch tok.Text {
+		case "{{":
+			depth++
+		case "}}":
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case "|":
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// render returns the words of the template laid out in the canonical layout.
+func render(s string, words []word, exprs []expr) string {
+	var (
+		b      strings.Builder
+		unit   = indentUnit(words)
+		indent string
+	)
+	for i, w := range words {
+		var prev word
+		if i > 0 {
+			prev = words[i-1]
+		}
+		newlines := strings.Count(w.gap, "\n")
+
+		switch {
+		case i == 0:
+			b.WriteString(trimLines(w.gap))
+			indent = indentOf(w.gap)
+		case w.role == roleHeader || (w.role == roleClose && exprs[w.expr].mode == headerAll):
+			b.WriteString(" ")
+		case newlines > 0:
+			indent = indentOf(w.gap)
+			if w.expr >= 0 {
+				e := exprs[w.expr]
+				indent = e.indent
+				switch w.role {
+				case roleText:
+					indent += unit + indentOf(w.gap)[e.minIndent:]
+				case roleOpen:
+					indent += unit
+				}
+			}
+			b.WriteString(strings.Repeat("\n", newlines) + indent)
+		case w.role != roleText || prev.role != roleText:
+			b.WriteString(" ")
+		default:
+			b.WriteString(w.gap)
+		}
+
+		b.WriteString(w.Text)
+		if w.role == roleOpen {
+			exprs[w.opens].indent = indent
+		}
+	}
+
+	last := words[len(words)-1]
+	if strings.Contains(s[last.Pos.Offset+len(last.Text):], "\n") {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// indentOf returns the indentation of the line the whitespace ends on, or
+// an empty string if it doesn't contain a line break.
+func indentOf(gap string) string {
+	i := strings.LastIndex(gap, "\n")
+	if i < 0 {
+		return ""
+	}
+	return gap[i+1:]
+}
+
+// trimLines removes the trailing whitespace of the lines of the leading
+// whitespace of the template.
+func trimLines(gap string) string {
+	i := strings.LastIndex(gap, "\n")
+	if i < 0 {
+		return gap
+	}
+	return strings.Repeat("\n", strings.Count(gap, "\n")) + gap[i+1:]
+}
+
+// indentUnit returns a tab if the first indented line of the template is
+// indented with a tab, or two spaces otherwise.
+func indentUnit(words []word) string {
+	for _, w := range words {
+		if indent := indentOf(w.gap); indent != "" {
+			if indent[0] == '\t' {
+				return "\t"
+			}
+			break
+		}
+	}
+	return "  "
+}
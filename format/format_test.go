@@ -0,0 +1,99 @@
+package format_test
+
+import (
+	"testing"
+
+	"github.com/sanggonlee/gosq/format"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSource(t *testing.T) {
+	cases := []struct {
+		desc          string
+		input         string
+		expected      string
+		expectedError string
+	}{
+		{
+			desc:     "Keyword spacing",
+			input:    "SELECT *   FROM products {{[if]}} WHERE   {{   [if]  .InStock   [then]   stock > 0   [else]  true   }}\n",
+			expected: "SELECT *   FROM products {{[if]}} WHERE {{ [if] .InStock [then] stock > 0 [else] true }}\n",
+		},
+		{
+			desc: "Header split across lines",
+			input: `SELECT * FROM products
+{{ [if]
+   .IncludeReviews [default]
+   false
+   [then] JOIN reviews ON true }}
+LIMIT {{ [default]
+  .Limit    50 }}`,
+			expected: `SELECT * FROM products
+{{ [if] .IncludeReviews [default] false [then] JOIN reviews ON true }}
+LIMIT {{ [default] .Limit 50 }}`,
+		},
+		{
+			desc: "Indentation of nested blocks",
+			input: `SELECT *
+FROM products
+    {{ [if] .IncludeReviews [then]
+LEFT JOIN reviews
+  ON reviews.product_id = products.id
+              {{ [if] .Recent [then]
+           AND reviews.created_at > now() - interval '1 day'
+     }}
+        [else]
+WHERE true
+}}
+`,
+			expected: `SELECT *
+FROM products
+    {{ [if] .IncludeReviews [then]
+      LEFT JOIN reviews
+        ON reviews.product_id = products.id
+      {{ [if] .Recent [then]
+        AND reviews.created_at > now() - interval '1 day'
+      }}
+    [else]
+      WHERE true
+    }}
+`,
+		},
+		{
+			desc:     "Tab indentation",
+			input:    "\tSELECT * FROM products\n\t{{ [if] .InStock [then]\n  WHERE stock > 0 }}",
+			expected: "\tSELECT * FROM products\n\t{{ [if] .InStock [then]\n\t\tWHERE stock > 0 }}",
+		},
+		{
+			desc:     "Trailing whitespace and blank lines",
+			input:    "SELECT *   \n\n   \nFROM products  \n\n",
+			expected: "SELECT *\n\n\nFROM products\n",
+		},
+		{
+			desc:     "Pipelines, defines and params",
+			input:    "{{ [params]\n  .Search:string!\n  .Limit:int=50 }}\n{{ [define]  active   deleted_at IS NULL }}\nWHERE name LIKE {{ .Search  |  like }} AND {{   [include]   active }}",
+			expected: "{{ [params]\n  .Search:string!\n  .Limit:int=50 }}\n{{ [define] active deleted_at IS NULL }}\nWHERE name LIKE {{ .Search | like }} AND {{ [include] active }}",
+		},
+		{
+			desc:          "Invalid template",
+			input:         "SELECT * FROM products {{ [if] .InStock",
+			expectedError: "unclosed {{",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := format.Source([]byte(c.input))
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, string(result))
+
+			// Formatting is idempotent.
+			again, err := format.Source(result)
+			assert.NoError(t, err)
+			assert.Equal(t, string(result), string(again))
+		})
+	}
+}
@@ -1,9 +1,15 @@
 module github.com/sanggonlee/gosq
 
-go 1.15
+go 1.18
 
 require (
 	github.com/go-test/deep v1.0.7
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.8.4
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 )
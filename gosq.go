@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -20,11 +22,58 @@ import (
 // in the template.
 //
 // The values of the parameters can be anything, but it will be evaluated as a
-// string, using `fmt.Sprintf("%v", v)`.
+// string, using `fmt.Sprintf("%v", v)`. A driver.Valuer, such as
+// sql.NullString, is evaluated by its value, or NULL. As a predicate, it's
+// false for NULL, and true for any other value unless it's a boolean.
 //
 // The following are the supported syntax in the expressions:
 //  - {{ [if] predicate [then] clause }}
 //  - {{ [if] predicate [then] clause [else] clause }}
+//  - {{ [default] .Variable value }}
+//  - {{ [ident] .Variable }}
+//  - {{ [oneof] .Variable "value" "value"... }}
+//  - {{ [dir] .Variable }}
+//  - {{ [raw] .Variable }}
+//  - {{ [define] name clause }}
+//  - {{ [include] name }}
+//  - {{ .Variable | function args... | function args... }}
+//
+// A [default] expression evaluates to the value of the variable, or to the
+// given default value if the variable is missing or has the zero value.
+// A predicate can have a default value as well, which is used only if the
+// variable is missing: {{ [if] .Variable [default] true [then] clause }}
+//
+// An [ident] expression evaluates to the value of the variable quoted as an
+// identifier, such as "products" or "public"."products", for dynamic table and
+// column names which can't be bound. The quotes follow the dialect given by
+// WithDialect, and a value with characters other than letters, digits, _ and
+// $ in each dot-separated part is an error.
+//
+// A [oneof] expression evaluates to the value of the variable, which must be
+// one of the listed values, such as a user-controlled sort column. The values
+// can be declared with WithAllowlist instead. A [dir] expression only allows
+// ASC or DESC, in any case, or an empty string.
+//
+// A [raw] expression evaluates to the value of the variable verbatim, even
+// with CompileArgs, which binds the values of other variables.
+//
+// A [define] expression defines a partial, which evaluates to nothing where
+// it's defined, and to the clause wherever it's included with [include]. To
+// share partials between templates, use a TemplateSet.
+//
+// A pipeline passes the value of the variable through the functions in turn,
+// as the last argument of each. See WithFuncs for the built-in functions, and
+// to add custom ones. A pipeline resulting in a boolean can be used as a
+// predicate too: {{ [if] .Role | isAdmin [then] clause }}
+//
+// The template can declare its parameters in a header, in which case "args"
+// are validated against the declarations before the template is evaluated:
+//  {{ [params] .Category:string! .Limit:int=50 .IncludeReviews:bool }}
+// The supported types are string, int, float and bool, or any type if it's
+// omitted. A parameter followed by "!" is required, and one followed by
+// "=value" takes the default value if it's missing or zero. Other parameters
+// which are missing take the zero value of their type, or NULL if it's
+// omitted, which is false in a predicate.
 //
 // Recursive expressions are supported, as long as they're parts of a [then] or
 // [else] clause. For example:
@@ -34,31 +83,61 @@ import (
 //
 // If you need grammar for a more complex expression and you think it's a common
 // use case, please file an issue on GitHub.
-func Compile(template string, args interface{}) (string, error) {
-	if args == nil {
-		return template, nil
-	}
-
-	argsLookup, err := initArgsLookupTable(args)
-	if err != nil {
-		return "", err
-	}
-
-	tt := ast.BuildTokenTree(template)
-
-	st, err := tt.Parse()
-	if err != nil {
-		return "", errors.Wrap(err, "building AST")
-	}
+//
+// By default, a variable (a word starting with a dot, such as .IncludeReviews)
+// which isn't given in "args" is left as-is, and is an error in a predicate.
+// Use WithMissingKey(MissingKeyError) to report any missing variable by name,
+// which a Compiler does by default.
+//
+// Compile parses the template on every call. To parse a template only once,
+// use a Compiler.
+func Compile(template string, args interface{}, opts ...Option) (string, error) {
+	c := &Compiler{opts: newPackageOptions(opts)}
+	return c.Compile(template, args)
+}
 
-	if err = st.SubstituteVars(argsLookup); err != nil {
-		return "", errors.Wrap(err, "substituting args")
-	}
+// CompileArgs is similar to Compile, but binds the values of the variables
+// substituted in the template instead of inlining them, and returns the query
+// along with the bound args:
+//
+//	q, args, err := gosq.CompileArgs(`SELECT * FROM products WHERE category = .Category`, params)
+//	// SELECT * FROM products WHERE category = $1
+//	rows, err := db.Query(q, args...)
+//
+// The placeholders follow the dialect given by WithDialect. To splice a
+// trusted SQL fragment verbatim, pass it as a Raw value, or reference it with
+// {{ [raw] .Variable }}. The values of [ident], [oneof] and [dir] expressions
+// are validated, so they're inlined as well.
+func CompileArgs(template string, args interface{}, opts ...Option) (string, []interface{}, error) {
+	c := &Compiler{opts: newPackageOptions(opts)}
+	return c.CompileArgs(template, args)
+}
 
-	return st.Evaluate(), nil
+// Parse parses the template once, so it can be asked what it needs, such as
+// the variables it references and its [if] blocks, or compiled many times:
+//
+//	tmpl, err := gosq.Parse(`SELECT * FROM products {{ [if] .InStock [then] WHERE stock > 0 }}`)
+//	for _, v := range tmpl.Variables() {
+//		fmt.Println(v.Name, v.Predicate) // .InStock true
+//	}
+func Parse(template string, opts ...Option) (*Template, error) {
+	c := &Compiler{opts: newOptions(opts)}
+	return c.Parse(template)
 }
 
-func initArgsLookupTable(args interface{}) (map[string]interface{}, error) {
+// Raw is a trusted SQL fragment, such as a subquery built elsewhere. It's
+// inlined verbatim by CompileArgs, and by Execute and ExecuteArgs with
+// WithAutoEscape, where any other value is bound or escaped. Wrapping a value
+// in Raw marks the unsafe inlining for code review:
+//
+//	args := map[string]interface{}{
+//		"Filter": gosq.Raw("id IN (SELECT product_id FROM featured)"),
+//	}
+type Raw = ast.Raw
+
+// initArgsLookupTable builds the table of variables from the args, validated
+// against the parameters declared in the template, if any.
+func initArgsLookupTable(args interface{}, params []ast.Param) (map[string]interface{}, error) {
 	var _m map[string]interface{}
 	var ok bool
 
@@ -74,9 +153,58 @@ func initArgsLookupTable(args interface{}) (map[string]interface{}, error) {
 		delete(m, k)
 	}
 
+	for _, p := range params {
+		v, ok := m[p.Name]
+		v, err := p.Resolve(v, ok)
+		if err != nil {
+			return nil, err
+		}
+		m[p.Name] = v
+	}
+
 	return m, nil
 }
 
+// checkUnusedArgs returns an error listing the names of the args which are not
+// marked as used.
+func checkUnusedArgs(names []string, used map[string]bool) error {
+	var unused []string
+	for _, name := range names {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) == 0 {
+		return nil
+	}
+	sort.Strings(unused)
+	return fmt.Errorf("unused args: %s", strings.Join(unused, ", "))
+}
+
+// argNames returns the names of the args which can be referenced in the
+// template, that is the keys of a map or the exported fields of a struct.
+func argNames(args interface{}) []string {
+	var names []string
+	v := reflect.Indirect(reflect.ValueOf(args))
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, k := range v.MapKeys() {
+			names = append(names, k.String())
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" {
+				names = append(names, f.Name)
+			}
+		}
+	}
+	return names
+}
+
 func convertStructToMap(args interface{}) map[string]interface{} {
 	m := make(map[string]interface{})
 	t := reflect.TypeOf(args)
@@ -95,20 +223,38 @@ func convertStructToMap(args interface{}) map[string]interface{} {
 // The if-else-then expression equivalent to the Compile function would be:
 //
 // {{if predicate}} clause {{else}} clause {{end}}
-func Execute(str string, args interface{}) (string, error) {
-	tmpl, err := template.New("gosq").Parse(str)
-	if err != nil {
-		return "", errors.Wrap(err, "parsing template")
-	}
-
-	var buf bytes.Buffer
-	if err = tmpl.Execute(&buf, args); err != nil {
-		return "", errors.Wrap(err, "executing template")
-	}
+//
+// The same options as Compile are supported. By default, a missing key is
+// printed as "<no value>", as text/template does, unless WithMissingKey is
+// given.
+// The SQL-aware functions ident, literal and join are available too, see
+// ExecuteArgs for binding args.
+func Execute(str string, args interface{}, opts ...Option) (string, error) {
+	c := &Compiler{opts: newPackageOptions(opts)}
+	return c.Execute(str, args)
+}
 
-	return buf.String(), nil
+// ExecuteArgs is similar to Execute, but also returns the args bound in the
+// template, to be passed to the query along with it. The following SQL-aware
+// functions are available in the template (see Binder for details):
+//
+//	SELECT {{ ident .Column }}
+//	FROM products
+//	WHERE category = {{ bind .Category }}
+//	AND id IN {{ in .IDs }}
+//	AND status = {{ literal .Status }}
+//
+// The placeholders of the bound args follow the dialect given by WithDialect.
+// Execute returns an error if the template binds any args.
+func ExecuteArgs(str string, args interface{}, opts ...Option) (string, []interface{}, error) {
+	c := &Compiler{opts: newPackageOptions(opts)}
+	return c.ExecuteArgs(str, args)
 }
 
+// ExecuteWithOption is similar to Execute, but takes an option string of the
+// text/template package, such as "missingkey=zero".
+//
+// Deprecated: Use Execute with WithMissingKey instead.
 func ExecuteWithOption(str string, args interface{}, option string) (string, error) {
 	// Default option, error out if key is missing
 	if option == "" {
@@ -1,10 +1,12 @@
 package gosq_test
 
 import (
+	"database/sql"
 	"errors"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sanggonlee/gosq"
 	"github.com/stretchr/testify/assert"
@@ -24,6 +26,12 @@ func TestCompile(t *testing.T) {
 			inputArgs:     nil,
 			expected:      `SELECT *	FROM products`,
 		},
+		{
+			desc:          "Bitwise or outside of expressions",
+			inputTemplate: `SELECT * FROM t WHERE flags | 4 = 4 {{ [if] .A [then] AND a }}`,
+			inputArgs:     map[string]interface{}{"A": true},
+			expected:      `SELECT * FROM t WHERE flags | 4 = 4 AND a`,
+		},
 		{
 			desc: "Simple case of falsey substitute from map",
 			inputTemplate: `
@@ -260,6 +268,684 @@ func TestCompile(t *testing.T) {
 	}
 }
 
+func TestCompile_NilArgs(t *testing.T) {
+	cases := []struct {
+		desc          string
+		inputTemplate string
+		inputOptions  []gosq.Option
+		expected      string
+		expectedError string
+	}{
+		{
+			desc:          "Template is evaluated",
+			inputTemplate: `SELECT * FROM products {{ [if] .Deleted [default] false [then] WHERE deleted_at IS NOT NULL }}`,
+			expected:      `SELECT * FROM products`,
+		},
+		{
+			desc:          "Template is parsed",
+			inputTemplate: `SELECT }}`,
+			expectedError: "1:8: unexpected }} without matching {{",
+		},
+		{
+			desc:          "Variables are checked",
+			inputTemplate: `SELECT * FROM products WHERE category = .Category`,
+			inputOptions:  []gosq.Option{gosq.WithMissingKey(gosq.MissingKeyError)},
+			expectedError: "no value given for variable .Category",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(c.inputTemplate, nil, c.inputOptions...)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+}
+
+func TestCompile_MissingKey(t *testing.T) {
+	inputTemplate := `
+		SELECT
+			products.*
+			{{ [if] .IncludReviews [then] ,json_agg(reviews) AS reviews }}
+		FROM products
+		WHERE category = .Category
+	`
+	cases := []struct {
+		desc          string
+		inputArgs     interface{}
+		inputOptions  []gosq.Option
+		expected      string
+		expectedError string
+	}{
+		{
+			desc: "Missing predicate errors",
+			inputArgs: map[string]interface{}{
+				"IncludeReviews": true,
+				"Category":       "'electronics'",
+			},
+			inputOptions:  []gosq.Option{gosq.WithMissingKey(gosq.MissingKeyError)},
+			expectedError: "no value given for variable .IncludReviews",
+		},
+		{
+			desc: "Missing substitution errors",
+			inputArgs: struct {
+				IncludReviews bool
+			}{
+				IncludReviews: true,
+			},
+			inputOptions:  []gosq.Option{gosq.WithMissingKey(gosq.MissingKeyError)},
+			expectedError: "no value given for variable .Category",
+		},
+		{
+			desc: "Missing substitution is left as-is by default",
+			inputArgs: map[string]interface{}{
+				"IncludReviews": false,
+			},
+			expected: `
+				SELECT
+					products.*
+				FROM products
+				WHERE category = .Category
+			`,
+		},
+		{
+			desc: "Missing variables evaluate to zero values",
+			inputArgs: map[string]interface{}{
+				"IncludeReviews": true,
+			},
+			inputOptions: []gosq.Option{gosq.WithMissingKey(gosq.MissingKeyZero)},
+			expected: `
+				SELECT
+					products.*
+				FROM products
+				WHERE category =
+			`,
+		},
+		{
+			desc: "Missing substitution is left as-is",
+			inputArgs: map[string]interface{}{
+				"IncludReviews": true,
+			},
+			inputOptions: []gosq.Option{gosq.WithMissingKey(gosq.MissingKeyLeave)},
+			expected: `
+				SELECT
+					products.*
+					,json_agg(reviews) AS reviews
+				FROM products
+				WHERE category = .Category
+			`,
+		},
+		{
+			desc: "Missing predicate is not a boolean when left as-is",
+			inputArgs: map[string]interface{}{
+				"Category": "'electronics'",
+			},
+			inputOptions:  []gosq.Option{gosq.WithMissingKey(gosq.MissingKeyLeave)},
+			expectedError: "predicate .IncludReviews must be a boolean expression",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(inputTemplate, c.inputArgs, c.inputOptions...)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, whitespaceNormalized(c.expected), whitespaceNormalized(result))
+		})
+	}
+}
+
+func TestCompile_Default(t *testing.T) {
+	inputTemplate := `
+		SELECT *
+		FROM products
+		ORDER BY {{ [default] .Sort created_at }} {{ [if] .Desc [default] true [then] DESC [else] ASC }}
+		LIMIT {{ [default] .Limit 50 }}
+	`
+	cases := []struct {
+		desc      string
+		inputArgs interface{}
+		expected  string
+	}{
+		{
+			desc:      "Missing variables take default values",
+			inputArgs: map[string]interface{}{},
+			expected:  `SELECT * FROM products ORDER BY created_at DESC LIMIT 50`,
+		},
+		{
+			desc: "Zero values take default values, except in predicates",
+			inputArgs: struct {
+				Sort  string
+				Desc  bool
+				Limit int
+			}{},
+			expected: `SELECT * FROM products ORDER BY created_at ASC LIMIT 50`,
+		},
+		{
+			desc: "Given variables",
+			inputArgs: map[string]interface{}{
+				"Sort":  "price",
+				"Desc":  true,
+				"Limit": 10,
+			},
+			expected: `SELECT * FROM products ORDER BY price DESC LIMIT 10`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(inputTemplate, c.inputArgs)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+}
+
+func TestCompile_Ident(t *testing.T) {
+	inputTemplate := `
+		SELECT *
+		FROM {{ [ident] .Table }}
+		ORDER BY {{ [ident] .Sort }}
+	`
+	cases := []struct {
+		desc          string
+		inputArgs     interface{}
+		inputOptions  []gosq.Option
+		expected      string
+		expectedError string
+	}{
+		{
+			desc: "Postgres",
+			inputArgs: map[string]interface{}{
+				"Table": "tenant_42.products",
+				"Sort":  "price",
+			},
+			expected: `SELECT * FROM "tenant_42"."products" ORDER BY "price"`,
+		},
+		{
+			desc: "MySQL",
+			inputArgs: map[string]interface{}{
+				"Table": "products",
+				"Sort":  "price",
+			},
+			inputOptions: []gosq.Option{gosq.WithDialect(gosq.MySQL)},
+			expected:     "SELECT * FROM `products` ORDER BY `price`",
+		},
+		{
+			desc: "Invalid identifier",
+			inputArgs: map[string]interface{}{
+				"Table": "products",
+				"Sort":  "price; DROP TABLE products",
+			},
+			expectedError: `invalid identifier "price; DROP TABLE products"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(inputTemplate, c.inputArgs, c.inputOptions...)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+}
+
+func TestCompile_Allowlist(t *testing.T) {
+	inputTemplate := `
+		SELECT {{ [oneof] .Column }}
+		FROM products
+		ORDER BY {{ [oneof] .Sort "price" "created_at" }} {{ [dir] .Order }}
+	`
+	opts := []gosq.Option{gosq.WithAllowlist("Column", "name", "price")}
+	cases := []struct {
+		desc          string
+		inputArgs     interface{}
+		expected      string
+		expectedError string
+	}{
+		{
+			desc: "Allowed values",
+			inputArgs: map[string]interface{}{
+				"Column": "name",
+				"Sort":   "price",
+				"Order":  "desc",
+			},
+			expected: `SELECT name FROM products ORDER BY price DESC`,
+		},
+		{
+			desc: "Value not in the template allowlist",
+			inputArgs: map[string]interface{}{
+				"Column": "name",
+				"Sort":   "name",
+				"Order":  "",
+			},
+			expectedError: `.Sort must be one of price, created_at, got "name"`,
+		},
+		{
+			desc: "Value not in the Go allowlist",
+			inputArgs: map[string]interface{}{
+				"Column": "password",
+				"Sort":   "price",
+				"Order":  "",
+			},
+			expectedError: `.Column must be one of name, price, got "password"`,
+		},
+		{
+			desc: "Invalid direction",
+			inputArgs: map[string]interface{}{
+				"Column": "name",
+				"Sort":   "price",
+				"Order":  "sideways",
+			},
+			expectedError: `.Order must be ASC or DESC, got "sideways"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(inputTemplate, c.inputArgs, opts...)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+}
+
+func TestCompileArgs(t *testing.T) {
+	inputTemplate := `
+		SELECT *
+		FROM products
+		WHERE category = .Category
+		AND .Filter
+		{{ [if] .HasExtra [then] AND {{ [raw] .Extra }} }}
+		ORDER BY {{ [ident] .Sort }}
+		LIMIT .Limit
+	`
+	cases := []struct {
+		desc         string
+		inputArgs    interface{}
+		inputOptions []gosq.Option
+		expected     string
+		expectedArgs []interface{}
+	}{
+		{
+			desc: "Plain strings are bound",
+			inputArgs: map[string]interface{}{
+				"Category": "'; DROP TABLE products; --",
+				"Filter":   "TRUE OR 1=1",
+				"HasExtra": false,
+				"Extra":    "",
+				"Sort":     "price",
+				"Limit":    10,
+			},
+			expected:     `SELECT * FROM products WHERE category = $1 AND $2 ORDER BY "price" LIMIT $3`,
+			expectedArgs: []interface{}{"'; DROP TABLE products; --", "TRUE OR 1=1", 10},
+		},
+		{
+			desc: "Raw values are inlined",
+			inputArgs: map[string]interface{}{
+				"Category": "food",
+				"Filter":   gosq.Raw("id IN (SELECT product_id FROM featured)"),
+				"HasExtra": true,
+				"Extra":    "deleted_at IS NULL",
+				"Sort":     "price",
+				"Limit":    10,
+			},
+			inputOptions: []gosq.Option{gosq.WithDialect(gosq.MySQL)},
+			expected:     "SELECT * FROM products WHERE category = ? AND id IN (SELECT product_id FROM featured) AND deleted_at IS NULL ORDER BY `price` LIMIT ?",
+			expectedArgs: []interface{}{"food", 10},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, args, err := gosq.CompileArgs(inputTemplate, c.inputArgs, c.inputOptions...)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+			assert.Equal(t, c.expectedArgs, args)
+		})
+	}
+
+	// Compile inlines everything.
+	result, err := gosq.Compile(`WHERE .Filter`, map[string]interface{}{"Filter": gosq.Raw("id = 1")})
+	assert.NoError(t, err)
+	assert.Equal(t, `WHERE id = 1`, result)
+}
+
+func TestCompile_Inline(t *testing.T) {
+	inputTemplate := `
+		UPDATE products
+		SET name = .Name , price = .Price , active = .Active , image = .Image ,
+			created_at = .CreatedAt , deleted_at = .DeletedAt
+	`
+	args := map[string]interface{}{
+		"Name":      "O'Reilly",
+		"Price":     9.5,
+		"Active":    true,
+		"Image":     []byte{0xca, 0xfe},
+		"CreatedAt": time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		"DeletedAt": nil,
+	}
+	cases := []struct {
+		desc         string
+		inputOptions []gosq.Option
+		expected     string
+	}{
+		{
+			desc:         "Postgres",
+			inputOptions: []gosq.Option{gosq.WithInline()},
+			expected:     `UPDATE products SET name = 'O''Reilly' , price = 9.5 , active = TRUE , image = '\xcafe' , created_at = '2024-01-02T15:04:05Z' , deleted_at = NULL`,
+		},
+		{
+			desc:         "SQLite",
+			inputOptions: []gosq.Option{gosq.WithInline(), gosq.WithDialect(gosq.SQLite)},
+			expected:     `UPDATE products SET name = 'O''Reilly' , price = 9.5 , active = 1 , image = X'cafe' , created_at = '2024-01-02T15:04:05Z' , deleted_at = NULL`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(inputTemplate, args, c.inputOptions...)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+
+	_, err := gosq.Compile(`WHERE tags = .Tags`, map[string]interface{}{"Tags": []string{"a"}}, gosq.WithInline())
+	assert.ErrorContains(t, err, "unsupported literal type []string")
+}
+
+func TestCompileArgs_Valuer(t *testing.T) {
+	type product struct {
+		Name     sql.NullString
+		Category sql.NullString
+		Price    sql.NullInt64
+	}
+	inputTemplate := `
+		SELECT * FROM products
+		WHERE name = .Name
+		{{ [if] .Category [then] AND category = .Category }}
+		AND price < .Price
+	`
+	args := product{
+		Name:  sql.NullString{String: "apple", Valid: true},
+		Price: sql.NullInt64{Int64: 100, Valid: true},
+	}
+
+	result, boundArgs, err := gosq.CompileArgs(inputTemplate, args)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products WHERE name = $1 AND price < $2`, result)
+	assert.Equal(t, []interface{}{args.Name, args.Price}, boundArgs)
+
+	result, err = gosq.Compile(inputTemplate, args, gosq.WithInline())
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products WHERE name = 'apple' AND price < 100`, result)
+}
+
+func TestCompile_Params(t *testing.T) {
+	inputTemplate := `
+		{{ [params] .Category:string! .Limit:int=50 .IncludeReviews:bool }}
+		SELECT
+			products.*
+			{{ [if] .IncludeReviews [then] ,json_agg(reviews) AS reviews }}
+		FROM products
+		WHERE category = .Category
+		LIMIT .Limit
+	`
+	cases := []struct {
+		desc          string
+		inputArgs     interface{}
+		expected      string
+		expectedError string
+	}{
+		{
+			desc: "Optional params take defaults or zero values",
+			inputArgs: map[string]interface{}{
+				"Category": "'books'",
+			},
+			expected: `SELECT products.* FROM products WHERE category = 'books' LIMIT 50`,
+		},
+		{
+			desc: "All params given",
+			inputArgs: struct {
+				Category       string
+				Limit          int
+				IncludeReviews bool
+			}{
+				Category:       "'books'",
+				Limit:          10,
+				IncludeReviews: true,
+			},
+			expected: `SELECT products.* ,json_agg(reviews) AS reviews FROM products WHERE category = 'books' LIMIT 10`,
+		},
+		{
+			desc: "Missing required param",
+			inputArgs: map[string]interface{}{
+				"Limit": 10,
+			},
+			expectedError: "missing required parameter .Category",
+		},
+		{
+			desc: "Param of a wrong type",
+			inputArgs: map[string]interface{}{
+				"Category":       "'books'",
+				"IncludeReviews": "yes",
+			},
+			expectedError: "parameter .IncludeReviews must be bool, got string",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(inputTemplate, c.inputArgs)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+}
+
+func TestCompile_Pipeline(t *testing.T) {
+	inputTemplate := `
+		SELECT {{ .Cols | join ", " }}
+		FROM products
+		WHERE name LIKE {{ .Term | like }}
+		AND category = {{ .Category | lower | quote }}
+		AND tenant_id = {{ .Tenant | tenantID }}
+	`
+	result, err := gosq.Compile(inputTemplate, map[string]interface{}{
+		"Cols":     []string{"id", "name"},
+		"Term":     "50%",
+		"Category": "Books",
+		"Tenant":   "acme",
+	}, gosq.WithFuncs(gosq.FuncMap{
+		"tenantID": func(name string) int { return len(name) },
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT id, name FROM products WHERE name LIKE '%50\%%' ESCAPE '\' AND category = 'books' AND tenant_id = 4`, result)
+
+	_, err = gosq.Compile(inputTemplate, map[string]interface{}{
+		"Cols":     []string{"id", "name"},
+		"Term":     "50%",
+		"Category": "Books",
+		"Tenant":   "acme",
+	})
+	assert.ErrorContains(t, err, "function tenantID is not defined")
+}
+
+func TestCompile_LikeAndQuote(t *testing.T) {
+	inputTemplate := `WHERE name LIKE {{ .Term | like }} AND note = {{ .Note | quote }}`
+	args := map[string]interface{}{
+		"Term": `50%_[x]\`,
+		"Note": `\' OR 1=1 -- `,
+	}
+	cases := []struct {
+		dialect      gosq.Dialect
+		expected     string
+		expectedBind string
+		expectedArgs []interface{}
+	}{
+		{
+			dialect:      gosq.Postgres,
+			expected:     `WHERE name LIKE '%50\%\_[x]\\%' ESCAPE '\' AND note = '\'' OR 1=1 -- '`,
+			expectedBind: `WHERE name LIKE $1 ESCAPE '\' AND note = '\'' OR 1=1 -- '`,
+			expectedArgs: []interface{}{`%50\%\_[x]\\%`},
+		},
+		{
+			dialect:      gosq.MySQL,
+			expected:     `WHERE name LIKE '%50\\%\\_[x]\\\\%' ESCAPE '\\' AND note = '\\'' OR 1=1 -- '`,
+			expectedBind: `WHERE name LIKE ? ESCAPE '\\' AND note = '\\'' OR 1=1 -- '`,
+			expectedArgs: []interface{}{`%50\%\_[x]\\%`},
+		},
+		{
+			dialect:      gosq.SQLite,
+			expected:     `WHERE name LIKE '%50\%\_[x]\\%' ESCAPE '\' AND note = '\'' OR 1=1 -- '`,
+			expectedBind: `WHERE name LIKE ? ESCAPE '\' AND note = '\'' OR 1=1 -- '`,
+			expectedArgs: []interface{}{`%50\%\_[x]\\%`},
+		},
+		{
+			dialect:      gosq.SQLServer,
+			expected:     `WHERE name LIKE '%50\%\_\[x]\\%' ESCAPE '\' AND note = '\'' OR 1=1 -- '`,
+			expectedBind: `WHERE name LIKE @p1 ESCAPE '\' AND note = '\'' OR 1=1 -- '`,
+			expectedArgs: []interface{}{`%50\%\_\[x]\\%`},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.dialect.String(), func(t *testing.T) {
+			result, err := gosq.Compile(inputTemplate, args, gosq.WithDialect(c.dialect))
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+
+			result, bound, err := gosq.CompileArgs(inputTemplate, args, gosq.WithDialect(c.dialect))
+			assert.NoError(t, err)
+			assert.Equal(t, c.expectedBind, result)
+			assert.Equal(t, c.expectedArgs, bound)
+		})
+	}
+}
+
+func TestCompile_ParamsNotGiven(t *testing.T) {
+	cases := []struct {
+		desc          string
+		inputTemplate string
+		inputOptions  []gosq.Option
+		expected      string
+	}{
+		{
+			desc:          "Untyped param is NULL",
+			inputTemplate: `{{ [params] .Note }} UPDATE products SET note = .Note`,
+			expected:      `UPDATE products SET note = NULL`,
+		},
+		{
+			desc:          "Untyped param is inlined as NULL",
+			inputTemplate: `{{ [params] .Note }} UPDATE products SET note = .Note`,
+			inputOptions:  []gosq.Option{gosq.WithInline(), gosq.WithDialect(gosq.MySQL)},
+			expected:      `UPDATE products SET note = NULL`,
+		},
+		{
+			desc:          "Untyped param is false in a predicate",
+			inputTemplate: `{{ [params] .Deleted }} SELECT * FROM products {{ [if] .Deleted [then] WHERE deleted_at IS NOT NULL }}`,
+			expected:      `SELECT * FROM products`,
+		},
+		{
+			desc:          "Param taking its default isn't an unused arg",
+			inputTemplate: `{{ [params] .Limit:int=50 }} SELECT 1`,
+			inputOptions:  []gosq.Option{gosq.WithUnusedArgsCheck()},
+			expected:      `SELECT 1`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(c.inputTemplate, map[string]interface{}{}, c.inputOptions...)
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, result)
+		})
+	}
+}
+
+func TestCompile_UnusedArgsCheck(t *testing.T) {
+	inputTemplate := `
+		SELECT
+			products.*
+			{{ [if] .IncludeReviews [then] ,json_agg(reviews) AS reviews [else] {{ [if] .IncludeCount [then] ,count(*) }} }}
+		FROM products
+		LIMIT .Limit
+	`
+	cases := []struct {
+		desc          string
+		inputArgs     interface{}
+		inputOptions  []gosq.Option
+		expected      string
+		expectedError string
+	}{
+		{
+			desc: "All args are used, including in the branch not taken",
+			inputArgs: map[string]interface{}{
+				"IncludeReviews": true,
+				"IncludeCount":   true,
+				"Limit":          10,
+			},
+			inputOptions: []gosq.Option{gosq.WithUnusedArgsCheck()},
+			expected: `
+				SELECT
+					products.*
+					,json_agg(reviews) AS reviews
+				FROM products
+				LIMIT 10
+			`,
+		},
+		{
+			desc: "Unused args are reported",
+			inputArgs: struct {
+				IncludeReviews bool
+				IncludeCount   bool
+				Limit          int
+				Offset         int
+				Category       string
+			}{},
+			inputOptions:  []gosq.Option{gosq.WithUnusedArgsCheck()},
+			expectedError: "unused args: Category, Offset",
+		},
+		{
+			desc: "Unused args are ignored without the check",
+			inputArgs: map[string]interface{}{
+				"IncludeReviews": false,
+				"IncludeCount":   true,
+				"Limit":          10,
+				"Offset":         20,
+			},
+			expected: `
+				SELECT
+					products.*
+					,count(*)
+				FROM products
+				LIMIT 10
+			`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			result, err := gosq.Compile(inputTemplate, c.inputArgs, c.inputOptions...)
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, whitespaceNormalized(c.expected), whitespaceNormalized(result))
+		})
+	}
+}
+
 func TestExecute(t *testing.T) {
 	cases := []struct {
 		desc          string
@@ -488,6 +1174,12 @@ func TestExecute(t *testing.T) {
 				LIMIT 10
 			`,
 		},
+		{
+			desc:          "Missing key prints no value",
+			inputTemplate: `SELECT * FROM products LIMIT {{.Limit}}`,
+			inputArgs:     map[string]interface{}{},
+			expected:      `SELECT * FROM products LIMIT <no value>`,
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.desc, func(t *testing.T) {
@@ -807,6 +1499,42 @@ func TestExecuteWithOption(t *testing.T) {
 	}
 }
 
+func FuzzCompile(f *testing.F) {
+	seeds := []string{
+		`SELECT * FROM products`,
+		`SELECT * {{ [if] .A [then] , a }} FROM products`,
+		`SELECT * {{ [if] .A [then] , a [else] , b }} FROM products`,
+		`SELECT * {{ [if] .A [then] {{ [if] .B [then] , b }} }} FROM products`,
+		`SELECT * }} FROM products`,
+		`SELECT * {{ FROM products`,
+		`{{ }}`,
+	}
+	for _, s := range seeds {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, tmpl string) {
+		_, err := gosq.Compile(tmpl, map[string]interface{}{
+			"A": true,
+			"B": false,
+		})
+		var depth int
+		for _, field := range strings.Fields(tmpl) {
+			if field == "{{" {
+				depth++
+			} else if field == "}}" {
+				depth--
+			}
+			if depth < 0 {
+				break
+			}
+		}
+		if err == nil && depth != 0 {
+			t.Errorf("Expected error for unbalanced template %q", tmpl)
+		}
+	})
+}
+
 func whitespaceNormalized(s string) string {
 	whitespaceRegex := regexp.MustCompile(`\s+`)
 	return whitespaceRegex.ReplaceAllString(strings.TrimSpace(s), " ")
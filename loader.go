@@ -0,0 +1,186 @@
+package gosq
+
+import (
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	nameHeaderRegex = regexp.MustCompile(`^--\s*name:`)
+	queryNameRegex  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+)
+
+// LoadFS loads the named queries from the files of fsys matching the glob
+// pattern, such as "queries/*.sql", into a TemplateSet. Each query starts
+// with a header line naming it, and runs until the next header:
+//
+//	-- name: GetProducts
+//	SELECT * FROM products
+//	WHERE {{ [include] activeProducts }}
+//
+//	-- name: activeProducts
+//	deleted_at IS NULL
+//
+// A query is a template of the Compile syntax, and can include the other
+// queries and partials of all the files. Comments and blank lines before the
+// first header of a file are ignored, and so are the -- comments of a query,
+// so they can't comment out the rest of a query whose whitespace is
+// compacted. Errors are reported with the file and
+// line they're found at.
+//
+// Along with go:embed, this keeps the SQL next to the Go code:
+//
+//	//go:embed queries/*.sql
+//	var queryFiles embed.FS
+//
+//	var queries = must(gosq.LoadFS(queryFiles, "queries/*.sql"))
+func LoadFS(fsys fs.FS, pattern string, opts ...Option) (*TemplateSet, error) {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "matching %s", pattern)
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no files match %s", pattern)
+	}
+
+	var (
+		o       = newOptions(opts)
+		sources []templateSource
+		seen    = make(map[string]templateSource)
+	)
+	for _, file := range files {
+		b, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", file)
+		}
+		queries, err := splitQueries(file, string(b), o.dialect)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range queries {
+			if prev, ok := seen[q.name]; ok {
+				return nil, errors.Errorf("%s:%d: query %s is already defined at %s:%d",
+					q.file, q.line-1, q.name, prev.file, prev.line-1)
+			}
+			seen[q.name] = q
+			sources = append(sources, q)
+		}
+	}
+
+	return newTemplateSet(sources, o)
+}
+
+// splitQueries splits the content of a file into the named queries, each
+// starting at the line after its header. The -- comments of the queries are
+// stripped.
+func splitQueries(file, content string, d Dialect) ([]templateSource, error) {
+	var (
+		queries []templateSource
+		body    []string
+	)
+	flush := func() {
+		if len(queries) > 0 {
+			queries[len(queries)-1].text = stripComments(strings.Join(body, "\n"), d)
+		}
+		body = nil
+	}
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !nameHeaderRegex.MatchString(trimmed) {
+			if len(queries) == 0 && trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return nil, errors.Errorf("%s:%d: query without a -- name: header", file, i+1)
+			}
+			body = append(body, line)
+			continue
+		}
+
+		name := strings.TrimSpace(nameHeaderRegex.ReplaceAllString(trimmed, ""))
+		if !queryNameRegex.MatchString(name) {
+			return nil, errors.Errorf("%s:%d: invalid query name %q", file, i+1, name)
+		}
+		flush()
+		queries = append(queries, templateSource{name: name, file: file, line: i + 2})
+	}
+	flush()
+
+	if len(queries) == 0 {
+		return nil, errors.Errorf("%s: no -- name: header found", file)
+	}
+	return queries, nil
+}
+
+// stripComments strips the -- comments of the query which are outside of
+// string literals, quoted identifiers and block comments. A comment runs
+// until the end of its line, or until the }} closing the expression it's in.
+// The line breaks are kept, so the lines of the rest of the query stay the
+// same.
+func stripComments(query string, d Dialect) string {
+	var (
+		b strings.Builder
+		// depth is the number of expressions the scan is in.
+		depth int
+	)
+	for i := 0; i < len(query); i++ {
+		switch rest := query[i:]; {
+		case strings.HasPrefix(rest, "--"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			if close := strings.Index(rest[:end], "}}"); depth > 0 && close >= 0 {
+				end = close
+			}
+			i += end - 1
+			continue
+		case strings.HasPrefix(rest, "{{"):
+			depth++
+		case strings.HasPrefix(rest, "}}") && depth > 0:
+			depth--
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				b.WriteString(rest)
+				return b.String()
+			}
+			b.WriteString(rest[:end+4])
+			i += end + 3
+			continue
+		case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`' && d == MySQL:
+			end := closingQuote(rest, d)
+			b.WriteString(rest[:end])
+			i += end - 1
+			continue
+		case rest[0] == '$' && d == Postgres && (i == 0 || !isIdentChar(query[i-1])):
+			if tag := dollarTagRegex.FindString(rest); tag != "" {
+				end := len(rest)
+				if close := strings.Index(rest[len(tag):], tag); close >= 0 {
+					end = len(tag) + close + len(tag)
+				}
+				b.WriteString(rest[:end])
+				i += end - 1
+				continue
+			}
+		}
+		b.WriteByte(query[i])
+	}
+	return b.String()
+}
+
+// closingQuote returns the length of the quoted string or identifier s starts
+// with, up to its closing quote, or the length of s if it isn't closed. A
+// doubled quote closes and reopens it, which ends up the same.
+func closingQuote(s string, d Dialect) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && d == MySQL && s[0] != '`':
+			i++
+		case s[i] == s[0]:
+			return i + 1
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,116 @@
+package gosq_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/sanggonlee/gosq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"queries/products.sql": {Data: []byte(`-- Queries on products.
+
+-- name: GetProducts
+-- Lists the active products.
+SELECT * FROM products
+WHERE {{ [include] activeProducts }} -- only the active ones
+  -- Paginated by the caller.
+LIMIT .Limit
+
+-- name: CountProducts
+SELECT count(*) FROM products /* -- */
+WHERE {{ [include] activeProducts }} AND name <> '--' {{ [if] .Sold [then] AND sold -- sold out }}
+`)},
+		"queries/partials.sql": {Data: []byte(`-- name: activeProducts
+deleted_at IS NULL
+`)},
+		"queries/README.md": {Data: []byte(`Not a query`)},
+	}
+
+	set, err := gosq.LoadFS(fsys, "queries/*.sql", gosq.WithCompactWhitespace())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CountProducts", "GetProducts", "activeProducts"}, set.Names())
+
+	result, args, err := set.CompileArgs("GetProducts", map[string]interface{}{"Limit": 10})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products WHERE deleted_at IS NULL LIMIT $1`, result)
+	assert.Equal(t, []interface{}{10}, args)
+
+	result, err = set.Compile("CountProducts", map[string]interface{}{"Sold": true})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT count(*) FROM products /* -- */ WHERE deleted_at IS NULL AND name <> '--' AND sold`, result)
+}
+
+func TestLoadFS_Errors(t *testing.T) {
+	cases := []struct {
+		desc          string
+		input         fstest.MapFS
+		expectedError string
+	}{
+		{
+			desc: "No files",
+			input: fstest.MapFS{
+				"queries/a.txt": {Data: []byte(`-- name: A`)},
+			},
+			expectedError: "no files match queries/*.sql",
+		},
+		{
+			desc: "Query without a header",
+			input: fstest.MapFS{
+				"queries/a.sql": {Data: []byte("-- comment\nSELECT 1\n")},
+			},
+			expectedError: "queries/a.sql:2: query without a -- name: header",
+		},
+		{
+			desc: "Invalid name",
+			input: fstest.MapFS{
+				"queries/a.sql": {Data: []byte("-- name: Get Products\nSELECT 1\n")},
+			},
+			expectedError: `queries/a.sql:1: invalid query name "Get Products"`,
+		},
+		{
+			desc: "Duplicate name",
+			input: fstest.MapFS{
+				"queries/a.sql": {Data: []byte("-- name: A\nSELECT 1\n")},
+				"queries/b.sql": {Data: []byte("\n-- name: A\nSELECT 2\n")},
+			},
+			expectedError: "queries/b.sql:2: query A is already defined at queries/a.sql:1",
+		},
+		{
+			desc: "Syntax error with a position",
+			input: fstest.MapFS{
+				"queries/a.sql": {Data: []byte("-- name: A\nSELECT 1\n\n-- name: B\nSELECT *\nFROM {{ products\n")},
+			},
+			expectedError: "queries/a.sql:6:6: parsing query B: unclosed {{",
+		},
+		{
+			desc: "Syntax error after a comment line",
+			input: fstest.MapFS{
+				"queries/a.sql": {Data: []byte("-- name: A\n-- Lists everything.\nSELECT *\nFROM {{ products\n")},
+			},
+			expectedError: "queries/a.sql:4:6: parsing query A: unclosed {{",
+		},
+		{
+			desc: "Syntax error",
+			input: fstest.MapFS{
+				"queries/a.sql": {Data: []byte("-- name: A\nSELECT {{ [include] }}\n")},
+			},
+			expectedError: "queries/a.sql:2:8: parsing query A",
+		},
+		{
+			desc: "Undefined partial",
+			input: fstest.MapFS{
+				"queries/a.sql": {Data: []byte("-- name: A\nSELECT {{ [include] columns }}\n")},
+			},
+			expectedError: "template columns is not defined",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := gosq.LoadFS(c.input, "queries/*.sql")
+			assert.ErrorContains(t, err, c.expectedError)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package gosq
+
+import (
+	"strings"
+
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// Option configures how templates are compiled. The same options apply to
+// both the Compile and the Execute syntax.
+type Option func(*options)
+
+type options struct {
+	missingKey        MissingKey
+	checkUnusedArgs   bool
+	compactWhitespace bool
+	cache             bool
+	funcs             FuncMap
+	dialect           Dialect
+	autoEscape        bool
+	allowlists        map[string][]string
+	inline            bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		missingKey: MissingKeyError,
+		cache:      true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// newPackageOptions returns the options of the package level Compile and
+// Execute functions, which leave the missing variables as-is unless told
+// otherwise, as they always have.
+func newPackageOptions(opts []Option) *options {
+	return newOptions(append([]Option{WithMissingKey(MissingKeyLeave)}, opts...))
+}
+
+// templateOption returns the text/template option equivalent to the options.
+func (o *options) templateOption() string {
+	switch o.missingKey {
+	case MissingKeyZero:
+		return "missingkey=zero"
+	case MissingKeyLeave:
+		return "missingkey=default"
+	}
+	return "missingkey=error"
+}
+
+// format applies the whitespace options to the query.
+func (o *options) format(q string) string {
+	if !o.compactWhitespace {
+		return q
+	}
+	return strings.Join(strings.Fields(q), " ")
+}
+
+// MissingKey controls what happens when the template references a variable
+// which isn't given in the args.
+type MissingKey = ast.MissingKey
+
+const (
+	// MissingKeyError stops the compilation with an error naming the missing
+	// variable. This is the default of a Compiler, a Template and a
+	// TemplateSet.
+	MissingKeyError = ast.MissingKeyError
+	// MissingKeyZero evaluates a missing variable to its zero value: it's
+	// removed from a clause, and is false in a predicate.
+	MissingKeyZero = ast.MissingKeyZero
+	// MissingKeyLeave leaves the reference to a missing variable in the
+	// query as-is. With Execute, "<no value>" is printed instead. This is the
+	// default of the package level Compile, CompileArgs, Execute and
+	// ExecuteArgs.
+	MissingKeyLeave = ast.MissingKeyLeave
+)
+
+// WithMissingKey sets the policy for variables referenced in the template
+// which aren't given in the args.
+func WithMissingKey(mk MissingKey) Option {
+	return func(o *options) {
+		o.missingKey = mk
+	}
+}
+
+// WithUnusedArgsCheck makes it an error to give args which aren't referenced
+// anywhere in the template, in any of the predicates or clauses. This helps
+// catching stale args left over after the template has changed.
+func WithUnusedArgsCheck() Option {
+	return func(o *options) {
+		o.checkUnusedArgs = true
+	}
+}
+
+// WithCompactWhitespace collapses every run of whitespace in the resulting
+// query into a single space, and trims the whitespace around it. Compile
+// always produces such a query, so this only makes a difference to Execute.
+//
+// Note that the whitespace inside string literals of the query is collapsed
+// as well.
+func WithCompactWhitespace() Option {
+	return func(o *options) {
+		o.compactWhitespace = true
+	}
+}
+
+// WithCache sets whether a Compiler caches the parsed templates, up to 1000
+// of each syntax. It's enabled by default, and has no effect on the package
+// level functions, which never cache.
+func WithCache(enabled bool) Option {
+	return func(o *options) {
+		o.cache = enabled
+	}
+}
+
+// Dialect is the SQL dialect of the query, which determines the syntax of
+// bind placeholders, quoted identifiers and literals.
+type Dialect = ast.Dialect
+
+const (
+	// Postgres uses $1 placeholders and "double quoted" identifiers. This is
+	// the default.
+	Postgres = ast.Postgres
+	// MySQL uses ? placeholders and `backquoted` identifiers.
+	MySQL = ast.MySQL
+	// SQLite uses ? placeholders and "double quoted" identifiers.
+	SQLite = ast.SQLite
+	// SQLServer uses @p1 placeholders and [bracketed] identifiers.
+	SQLServer = ast.SQLServer
+)
+
+// WithDialect sets the SQL dialect of the queries.
+func WithDialect(d Dialect) Option {
+	return func(o *options) {
+		o.dialect = d
+	}
+}
+
+// WithAutoEscape makes Execute and ExecuteArgs escape the output of every
+// action of the text/template syntax according to its SQL context, the way
+// html/template does for HTML:
+//   - in a value position, the value is bound as an arg by ExecuteArgs, or
+//     inlined as a literal by Execute. A slice becomes a parenthesized list,
+//     and an empty one is an error, like with the in function.
+//   - after FROM, JOIN, UPDATE, INTO, TABLE or BY, or after a ".", the value
+//     is quoted as an identifier.
+//   - inside a quoted string or identifier, the value is escaped, including
+//     its backslashes in a MySQL string or a Postgres E'...' string.
+//   - inside a comment or a Postgres dollar-quoted string, the template is
+//     rejected.
+//
+// The output of the bind, in, ident and literal functions in a value position
+// is already safe, so it's left as-is, and so is a Raw value.
+func WithAutoEscape() Option {
+	return func(o *options) {
+		o.autoEscape = true
+	}
+}
+
+// WithInline makes Compile inline the values of the variables as SQL literals
+// of the dialect, rather than as they're printed by fmt: strings are quoted
+// and escaped, nil is NULL, time.Time is an ISO 8601 timestamp, []byte is a
+// hex literal, and booleans follow the dialect. This is meant for generating
+// scripts and debug output. For queries to run, prefer binding the values
+// with CompileArgs, which ignores this option.
+func WithInline() Option {
+	return func(o *options) {
+		o.inline = true
+	}
+}
+
+// WithAllowlist declares the values allowed for the variable of a [oneof]
+// expression which doesn't list them in the template, such as
+// {{ [oneof] .Sort }}. The name is given without the preceding dot.
+// Values listed in the template take precedence.
+func WithAllowlist(name string, values ...string) Option {
+	return func(o *options) {
+		allowlists := make(map[string][]string, len(o.allowlists)+1)
+		for k, v := range o.allowlists {
+			allowlists[k] = v
+		}
+		allowlists["."+name] = values
+		o.allowlists = allowlists
+	}
+}
+
+// FuncMap is a map of functions which can be called by name in a pipeline of
+// a template, such as {{ .Name | upper }}.
+//
+// Like in the text/template package, a function must return either a single
+// value, or a value and an error. The value of the previous command of the
+// pipeline is passed as the last argument of the function.
+type FuncMap = ast.FuncMap
+
+// WithFuncs adds the functions to the ones which can be called in a
+// pipeline of the Compile syntax, or in the text/template syntax of Execute.
+// A function of the same name as a built-in one replaces it. Calling
+// WithFuncs many times adds up the functions.
+//
+// See Binder for the functions built in to Execute.
+//
+// The built-in functions of Compile are:
+//   - upper, lower, trim: change the case of, or trim the spaces around a string
+//   - join: join the elements of a slice with a separator, as in {{ .Cols | join ", " }}
+//   - like: make a LIKE pattern matching any string containing the value,
+//     escaping the wildcards in it, along with its ESCAPE clause, as in
+//     name LIKE {{ .Term | like }}. The pattern is bound by CompileArgs, or
+//     quoted otherwise.
+//   - quote: quote a string as a SQL string literal of the dialect
+func WithFuncs(funcs FuncMap) Option {
+	return func(o *options) {
+		merged := make(FuncMap, len(o.funcs)+len(funcs))
+		for name, fn := range o.funcs {
+			merged[name] = fn
+		}
+		for name, fn := range funcs {
+			merged[name] = fn
+		}
+		o.funcs = merged
+	}
+}
@@ -0,0 +1,84 @@
+package gosq
+
+import (
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// Param is a parameter declared in the [params] header of a template.
+type Param = ast.Param
+
+// Variable is a variable referenced in a template.
+type Variable = ast.Variable
+
+// Block is an [if] block of a template.
+type Block = ast.Block
+
+// Template is a parsed template of the Compile syntax, which can be asked what
+// it needs, for example to generate code for it.
+type Template struct {
+	name string
+	tree *ast.SyntaxTree
+	opts *options
+}
+
+// Name returns the name of the template, or an empty string if it's not part
+// of a TemplateSet.
+func (t *Template) Name() string {
+	return t.name
+}
+
+// Params returns the parameters declared in the [params] header of the
+// template, if any.
+func (t *Template) Params() ([]Param, error) {
+	return t.tree.Params()
+}
+
+// Variables returns the variables referenced anywhere in the template,
+// including the partials it includes, in the order they first appear. A
+// variable referenced many times is listed once: it's a predicate or piped if
+// any of its references is, it's a value if any of its references is
+// substituted in the query, and it has a default only if all of them do.
+func (t *Template) Variables() []Variable {
+	var (
+		vars  []Variable
+		index = make(map[string]int)
+	)
+	for _, v := range t.tree.Variables() {
+		i, ok := index[v.Name]
+		if !ok {
+			index[v.Name] = len(vars)
+			vars = append(vars, v)
+			continue
+		}
+		vars[i].Predicate = vars[i].Predicate || v.Predicate
+		vars[i].Piped = vars[i].Piped || v.Piped
+		vars[i].Value = vars[i].Value || v.Value
+		vars[i].HasDefault = vars[i].HasDefault && v.HasDefault
+	}
+	return vars
+}
+
+// Blocks returns the [if] blocks of the template depth-first, in the order
+// they appear, including the ones of the partials it includes.
+// The clauses of the blocks are copies, which can be modified without
+// affecting the template.
+func (t *Template) Blocks() []Block {
+	return t.tree.Blocks()
+}
+
+// Compile compiles the template with the args, like the package level
+// Compile.
+func (t *Template) Compile(args interface{}) (string, error) {
+	q, _, err := t.compile(args, false)
+	return q, err
+}
+
+// CompileArgs compiles the template with the args, like the package level
+// CompileArgs.
+func (t *Template) CompileArgs(args interface{}) (string, []interface{}, error) {
+	return t.compile(args, true)
+}
+
+func (t *Template) compile(args interface{}, bind bool) (string, []interface{}, error) {
+	return compileTree(t.tree, t.opts, args, bind, nil)
+}
@@ -0,0 +1,152 @@
+package gosq
+
+import (
+	stderrors "errors"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// TemplateSet is a set of named templates of the Compile syntax, which share
+// their partials. A partial defined in any of the templates with
+// {{ [define] name ... }} can be included in any other with
+// {{ [include] name }}, and so can a whole template by its name:
+//
+//	set, err := gosq.NewTemplateSet(map[string]string{
+//		"filters": `{{ [define] activeProducts deleted_at IS NULL AND stock > 0 }}`,
+//		"search":  `SELECT * FROM products WHERE {{ [include] activeProducts }} AND name = .Name`,
+//	})
+//	q, err := set.Compile("search", args)
+//
+// The includes are resolved when the set is created, so a missing partial or
+// a cycle of includes is reported right away. A TemplateSet is safe for
+// concurrent use.
+type TemplateSet struct {
+	opts  *options
+	trees map[string]*ast.SyntaxTree
+}
+
+// NewTemplateSet parses the templates, given by name, and resolves the
+// partials they include.
+func NewTemplateSet(templates map[string]string, opts ...Option) (*TemplateSet, error) {
+	sources := make([]templateSource, 0, len(templates))
+	for _, name := range sortedKeys(templates) {
+		sources = append(sources, templateSource{name: name, text: templates[name]})
+	}
+	return newTemplateSet(sources, newOptions(opts))
+}
+
+// templateSource is the source of a template of a TemplateSet.
+type templateSource struct {
+	name string
+	text string
+	// file and line locate the text in a file, if it's loaded from one.
+	file string
+	line int
+}
+
+// wrap annotates err with the location of the source. An error at a position
+// of the template is located at that line of the file.
+func (src templateSource) wrap(err error, message string) error {
+	if src.file == "" {
+		return errors.Wrapf(err, "%s template %s", message, src.name)
+	}
+	var posErr *ast.Error
+	if stderrors.As(err, &posErr) {
+		return errors.Errorf("%s:%d:%d: %s query %s: %s",
+			src.file, src.line+posErr.Pos.Line-1, posErr.Pos.Column, message, src.name, posErr.Msg)
+	}
+	return errors.Wrapf(err, "%s:%d: %s query %s", src.file, src.line, message, src.name)
+}
+
+func newTemplateSet(sources []templateSource, o *options) (*TemplateSet, error) {
+	s := &TemplateSet{
+		opts:  o,
+		trees: make(map[string]*ast.SyntaxTree, len(sources)),
+	}
+
+	partials := make(map[string]*ast.SyntaxTree)
+	for _, src := range sources {
+		st, err := parseTree(src.text, s.opts)
+		if err != nil {
+			return nil, src.wrap(err, "parsing")
+		}
+		s.trees[src.name] = st
+
+		defines, err := st.Defines()
+		if err != nil {
+			return nil, src.wrap(err, "reading defines of")
+		}
+		if _, ok := defines[src.name]; ok {
+			return nil, src.wrap(errors.Errorf("template %s is defined more than once", src.name), "reading defines of")
+		}
+		defines[src.name] = st
+		for _, partial := range sortedTreeNames(defines) {
+			if _, ok := partials[partial]; ok {
+				return nil, src.wrap(errors.Errorf("template %s is defined more than once", partial), "reading defines of")
+			}
+			partials[partial] = defines[partial]
+		}
+	}
+
+	// The templates are partials themselves, so resolving the partials
+	// resolves all of them.
+	if err := ast.ResolveIncludes(partials); err != nil {
+		return nil, errors.Wrap(err, "resolving includes")
+	}
+	return s, nil
+}
+
+// Names returns the names of the templates in the set, in sorted order.
+func (s *TemplateSet) Names() []string {
+	return sortedTreeNames(s.trees)
+}
+
+// Lookup returns the template of the given name in the set, if any.
+func (s *TemplateSet) Lookup(name string) (*Template, bool) {
+	st, ok := s.trees[name]
+	if !ok {
+		return nil, false
+	}
+	return &Template{name: name, tree: st, opts: s.opts}, true
+}
+
+// Compile compiles the template of the given name with the args, like the
+// package level Compile.
+func (s *TemplateSet) Compile(name string, args interface{}) (string, error) {
+	q, _, err := s.compile(name, args, false)
+	return q, err
+}
+
+// CompileArgs compiles the template of the given name with the args, like the
+// package level CompileArgs.
+func (s *TemplateSet) CompileArgs(name string, args interface{}) (string, []interface{}, error) {
+	return s.compile(name, args, true)
+}
+
+func (s *TemplateSet) compile(name string, args interface{}, bind bool) (string, []interface{}, error) {
+	st, ok := s.trees[name]
+	if !ok {
+		return "", nil, errors.Errorf("template %s is not defined", name)
+	}
+	return compileTree(st, s.opts, args, bind, nil)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedTreeNames(trees map[string]*ast.SyntaxTree) []string {
+	names := make([]string, 0, len(trees))
+	for name := range trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,87 @@
+package gosq_test
+
+import (
+	"testing"
+
+	"github.com/sanggonlee/gosq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateSet(t *testing.T) {
+	set, err := gosq.NewTemplateSet(map[string]string{
+		"partials": `
+			{{ [define] filters deleted_at IS NULL {{ [if] .InStock [then] AND stock > 0 }} }}
+			{{ [define] joins LEFT JOIN reviews ON reviews.product_id = products.id }}
+		`,
+		"products": `
+			SELECT products.* FROM products
+			{{ [include] joins }}
+			WHERE category = .Category AND {{ [include] filters }}
+		`,
+		"count": `
+			SELECT count(*) FROM ( {{ [include] products }} ) AS p
+		`,
+	}, gosq.WithCompactWhitespace())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"count", "partials", "products"}, set.Names())
+
+	args := map[string]interface{}{
+		"Category": "food",
+		"InStock":  true,
+	}
+	result, err := set.Compile("products", args)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT products.* FROM products LEFT JOIN reviews ON reviews.product_id = products.id WHERE category = food AND deleted_at IS NULL AND stock > 0`, result)
+
+	result, boundArgs, err := set.CompileArgs("count", args)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT count(*) FROM ( SELECT products.* FROM products LEFT JOIN reviews ON reviews.product_id = products.id WHERE category = $1 AND deleted_at IS NULL AND stock > 0 ) AS p`, result)
+	assert.Equal(t, []interface{}{"food"}, boundArgs)
+
+	_, err = set.Compile("products", map[string]interface{}{"Category": "food"})
+	assert.ErrorContains(t, err, "no value given for variable .InStock")
+
+	_, err = set.Compile("nope", args)
+	assert.ErrorContains(t, err, "template nope is not defined")
+}
+
+func TestNewTemplateSet_Errors(t *testing.T) {
+	cases := []struct {
+		desc          string
+		input         map[string]string
+		expectedError string
+	}{
+		{
+			desc:          "Undefined partial",
+			input:         map[string]string{"a": `SELECT {{ [include] columns }}`},
+			expectedError: "template columns is not defined",
+		},
+		{
+			desc: "Cycle across templates",
+			input: map[string]string{
+				"a": `SELECT {{ [include] b }}`,
+				"b": `SELECT {{ [include] a }}`,
+			},
+			expectedError: "include cycle: a -> b -> a",
+		},
+		{
+			desc: "Partial defined twice",
+			input: map[string]string{
+				"a": `{{ [define] columns id }}`,
+				"b": `{{ [define] columns name }}`,
+			},
+			expectedError: "template columns is defined more than once",
+		},
+		{
+			desc:          "Parse error",
+			input:         map[string]string{"a": `SELECT {{ [include] }}`},
+			expectedError: "parsing template a",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := gosq.NewTemplateSet(c.input)
+			assert.ErrorContains(t, err, c.expectedError)
+		})
+	}
+}
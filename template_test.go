@@ -0,0 +1,46 @@
+package gosq_test
+
+import (
+	"testing"
+
+	"github.com/sanggonlee/gosq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tmpl, err := gosq.Parse(`
+		SELECT * FROM products
+		WHERE category = .Category
+		{{ [if] .InStock [then] AND stock > {{ [default] .MinStock 0 }} }}
+		{{ [if] .Category | is "food" [then] AND expires_at > now() [else] AND .Category IS NOT NULL }}
+	`, gosq.WithCompactWhitespace(), gosq.WithFuncs(gosq.FuncMap{
+		"is": func(want, got string) bool { return got == want },
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "", tmpl.Name())
+
+	assert.Equal(t, []gosq.Variable{
+		{Name: ".Category", Predicate: true, Piped: true, Value: true},
+		{Name: ".InStock", Predicate: true},
+		{Name: ".MinStock", HasDefault: true, Value: true},
+	}, tmpl.Variables())
+
+	blocks := tmpl.Blocks()
+	if assert.Len(t, blocks, 2) {
+		assert.Equal(t, ".InStock", blocks[0].Predicate)
+		assert.Nil(t, blocks[0].Else)
+		assert.Equal(t, `.Category | is "food"`, blocks[1].Predicate)
+		assert.NotNil(t, blocks[1].Else)
+	}
+
+	result, args, err := tmpl.CompileArgs(map[string]interface{}{
+		"Category": "food",
+		"InStock":  true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products WHERE category = $1 AND stock > 0 AND expires_at > now()`, result)
+	assert.Equal(t, []interface{}{"food"}, args)
+
+	_, err = gosq.Parse(`SELECT * FROM products {{ [if] .InStock }}`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,227 @@
+package gosq
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// Variant is a combination of the branches of the [if] blocks of a template,
+// as enumerated by Template.Variants.
+type Variant struct {
+	// Predicates are the values of the predicates of the [if] blocks the
+	// variant goes through, by predicate as written in the template, such as
+	// .InStock or .Role | isAdmin. The ones of the blocks nested in a branch
+	// which isn't taken are left out.
+	Predicates map[string]bool
+	// Query is the query of the variant, with the values of the variables
+	// bound as placeholders of the dialect. The identifiers of [ident] blocks
+	// are their variables' names, a [oneof] block has its first allowed value
+	// and a [dir] block the default direction. A [raw] block is NULL, which is
+	// valid wherever an expression is.
+	Query string
+
+	tmpl *Template
+}
+
+// Variants returns every reachable combination of the branches of the [if]
+// blocks of the template, so each of them can be checked, for example by a
+// SQL parser or by EXPLAIN:
+//
+//	variants, err := tmpl.Variants()
+//	for _, v := range variants {
+//		if _, err := db.Prepare(v.Query); err != nil {
+//			t.Errorf("variant %v: %v", v.Predicates, err)
+//		}
+//	}
+//
+// The blocks with the same predicate take the same branch, and the ones
+// nested in a branch which isn't taken aren't combined, so there can be much
+// fewer variants than 2^N for N blocks. The variants are listed depth-first,
+// starting with the one where every predicate is false, and the ones with the
+// same query as a previous one are left out.
+func (t *Template) Variants() ([]Variant, error) {
+	env := &ast.Env{
+		Vars:       variantVars(t.tree, t.opts),
+		Funcs:      t.opts.funcs,
+		Dialect:    t.opts.dialect,
+		Allowlists: t.opts.allowlists,
+	}
+
+	var (
+		variants []Variant
+		seen     = make(map[string]bool)
+		// choices are the values decided for the predicates so far, in the
+		// order they're reached.
+		choices []bool
+	)
+	for {
+		var (
+			reached    int
+			predicates = make(map[string]bool)
+		)
+		env.Predicate = func(predicate string) bool {
+			if v, ok := predicates[predicate]; ok {
+				return v
+			}
+			if reached == len(choices) {
+				choices = append(choices, false)
+			}
+			v := choices[reached]
+			reached++
+			predicates[predicate] = v
+			return v
+		}
+		b := &Binder{Dialect: t.opts.dialect}
+		env.Bind = b.bind
+
+		q, err := t.tree.Evaluate(env)
+		if err != nil {
+			return nil, errors.Wrap(err, "evaluating template")
+		}
+		if q = t.opts.format(q); !seen[q] {
+			seen[q] = true
+			variants = append(variants, Variant{Predicates: predicates, Query: q, tmpl: t})
+		}
+
+		// Take the other branch of the last predicate which hasn't taken both
+		// yet, and decide the ones reached after it anew.
+		choices = choices[:reached]
+		for len(choices) > 0 && choices[len(choices)-1] {
+			choices = choices[:len(choices)-1]
+		}
+		if len(choices) == 0 {
+			return variants, nil
+		}
+		choices[len(choices)-1] = true
+	}
+}
+
+// Compile compiles the template with the args, taking the branches of the
+// variant rather than evaluating the predicates. Only the variables of the
+// branches taken are needed.
+func (v Variant) Compile(args interface{}) (string, error) {
+	q, _, err := v.compile(args, false)
+	return q, err
+}
+
+// CompileArgs is similar to Compile, but binds the values of the variables as
+// the package level CompileArgs does.
+func (v Variant) CompileArgs(args interface{}) (string, []interface{}, error) {
+	return v.compile(args, true)
+}
+
+func (v Variant) compile(args interface{}, bind bool) (string, []interface{}, error) {
+	return compileTree(v.tmpl.tree, v.tmpl.opts, args, bind, v.Predicates)
+}
+
+// rawSample is the value of the variables of [raw] blocks in the variants.
+const rawSample = "NULL"
+
+// variantVars returns the values of the variables of the tree to evaluate the
+// variants with. They're bound as placeholders, so they only need to be of the
+// right type: the declared one of a parameter, or the one a function of a
+// pipeline takes. The others are nil, unless they need to be valid values of a
+// block.
+func variantVars(st *ast.SyntaxTree, o *options) map[string]interface{} {
+	vars := make(map[string]interface{})
+	for _, v := range st.Variables() {
+		vars[v.Name] = nil
+	}
+	if params, err := st.Params(); err == nil {
+		for _, p := range params {
+			// The default of the parameter, or else the zero value of its
+			// type.
+			p.Required = false
+			vars[p.Name], _ = p.Resolve(nil, false)
+		}
+	}
+	sampleValues(st, o, vars)
+	return vars
+}
+
+// sampleValues sets the values of the variables of the pipelines, and of the
+// [ident], [oneof], [dir] and [raw] blocks of the node, including the ones of
+// the partials it includes.
+func sampleValues(node ast.LanguageNode, o *options, vars map[string]interface{}) {
+	ast.Walk(node, visitor(func(n ast.LanguageNode) {
+		switch n := n.(type) {
+		case *ast.Pipeline:
+			samplePipeline(n, o, vars)
+		case *ast.IdentBlock:
+			vars[n.Name] = strings.TrimPrefix(n.Name, ".")
+		case *ast.AllowlistBlock:
+			values := n.Values
+			if len(values) == 0 {
+				values = o.allowlists[n.Name]
+			}
+			vars[n.Name] = ""
+			if n.Keyword == "[oneof]" && len(values) > 0 {
+				vars[n.Name] = values[0]
+			}
+		case *ast.RawBlock:
+			vars[n.Name] = rawSample
+		case *ast.IncludeBlock:
+			if n.Tree != nil {
+				sampleValues(n.Tree, o, vars)
+			}
+		}
+	}))
+}
+
+// samplePipeline sets the values of the variables of the pipeline which are
+// passed to a function, to the zero value of the parameter they're passed as.
+// A variable passed to a parameter of any type is left as-is, except for the
+// slice of the built-in join.
+func samplePipeline(p *ast.Pipeline, o *options, vars map[string]interface{}) {
+	for i, cmd := range p.Cmds {
+		fn, custom := o.funcs[cmd.Name]
+		if !custom {
+			fn = ast.Builtins()[cmd.Name]
+		}
+		t := reflect.TypeOf(fn)
+		if t == nil || t.Kind() != reflect.Func {
+			continue
+		}
+
+		operands := cmd.Args
+		if i == 0 {
+			// The operand of the pipeline is the last argument of the first
+			// call.
+			operands = append(append([]ast.Operand(nil), cmd.Args...), p.Operand)
+		}
+		for j, arg := range operands {
+			if arg.Variable == "" {
+				continue
+			}
+			var in reflect.Type
+			switch {
+			case t.IsVariadic() && j >= t.NumIn()-1:
+				in = t.In(t.NumIn() - 1).Elem()
+			case j < t.NumIn():
+				in = t.In(j)
+			default:
+				continue
+			}
+
+			switch {
+			case cmd.Name == "join" && !custom && j == len(operands)-1:
+				vars[arg.Variable] = []string{}
+			case in.Kind() != reflect.Interface:
+				vars[arg.Variable] = reflect.Zero(in).Interface()
+			}
+		}
+	}
+}
+
+type visitor func(ast.LanguageNode)
+
+func (f visitor) Visit(node ast.LanguageNode) ast.Visitor {
+	if node == nil {
+		return nil
+	}
+	f(node)
+	return f
+}
@@ -0,0 +1,137 @@
+package gosq_test
+
+import (
+	"testing"
+
+	"github.com/sanggonlee/gosq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Variants(t *testing.T) {
+	type variant struct {
+		predicates map[string]bool
+		query      string
+	}
+	cases := []struct {
+		desc          string
+		template      string
+		opts          []gosq.Option
+		expected      []variant
+		expectedError string
+	}{
+		{
+			desc:     "No blocks",
+			template: `SELECT * FROM products WHERE category = .Category`,
+			expected: []variant{
+				{map[string]bool{}, `SELECT * FROM products WHERE category = $1`},
+			},
+		},
+		{
+			desc: "Independent blocks",
+			template: `SELECT * FROM products WHERE true
+				{{ [if] .InStock [then] AND stock > 0 }}
+				{{ [if] .OnSale [then] AND on_sale [else] AND price > .MinPrice }}`,
+			expected: []variant{
+				{map[string]bool{".InStock": false, ".OnSale": false}, `SELECT * FROM products WHERE true AND price > $1`},
+				{map[string]bool{".InStock": false, ".OnSale": true}, `SELECT * FROM products WHERE true AND on_sale`},
+				{map[string]bool{".InStock": true, ".OnSale": false}, `SELECT * FROM products WHERE true AND stock > 0 AND price > $1`},
+				{map[string]bool{".InStock": true, ".OnSale": true}, `SELECT * FROM products WHERE true AND stock > 0 AND on_sale`},
+			},
+		},
+		{
+			desc: "Nested and repeated predicates",
+			template: `SELECT * FROM products
+				{{ [if] .Filter [then] WHERE category = .Category {{ [if] .InStock [then] AND stock > 0 }} }}
+				{{ [if] .Filter [then] ORDER BY name }}`,
+			expected: []variant{
+				{map[string]bool{".Filter": false}, `SELECT * FROM products`},
+				{map[string]bool{".Filter": true, ".InStock": false}, `SELECT * FROM products WHERE category = $1 ORDER BY name`},
+				{map[string]bool{".Filter": true, ".InStock": true}, `SELECT * FROM products WHERE category = $1 AND stock > 0 ORDER BY name`},
+			},
+		},
+		{
+			desc: "Same query",
+			template: `SELECT * FROM products
+				{{ [if] .A [then] WHERE a [else] {{ [if] .B [then] WHERE a }} }}`,
+			expected: []variant{
+				{map[string]bool{".A": false, ".B": false}, `SELECT * FROM products`},
+				{map[string]bool{".A": false, ".B": true}, `SELECT * FROM products WHERE a`},
+			},
+		},
+		{
+			desc: "Blocks with values",
+			template: `SELECT {{ [ident] .Column }} FROM products
+				{{ [if] .Role | isAdmin [then] WHERE {{ [raw] .Filter }} }}
+				ORDER BY {{ [oneof] .Sort "name" "price" }} {{ [dir] .Dir }}`,
+			opts: []gosq.Option{gosq.WithFuncs(gosq.FuncMap{
+				"isAdmin": func(role string) bool { return role == "admin" },
+			})},
+			expected: []variant{
+				{map[string]bool{".Role | isAdmin": false}, `SELECT "Column" FROM products ORDER BY name`},
+				{map[string]bool{".Role | isAdmin": true}, `SELECT "Column" FROM products WHERE NULL ORDER BY name`},
+			},
+		},
+		{
+			desc: "Typed values",
+			template: `{{ [params] .Limit:int! .Deleted:bool .Author:string="anonymous" }}
+				SELECT * FROM products WHERE name LIKE {{ .Search | like }}
+				AND tags = {{ .Tags | join "," }} AND author = .Author
+				AND deleted = .Deleted AND {{ [raw] .Filter }}
+				LIMIT .Limit`,
+			expected: []variant{
+				{map[string]bool{}, `SELECT * FROM products WHERE name LIKE $1 ESCAPE '\' AND tags = $2 AND author = $3 AND deleted = $4 AND NULL LIMIT $5`},
+			},
+		},
+		{
+			desc:          "Allowlist without values",
+			template:      `SELECT * FROM products ORDER BY {{ [oneof] .Sort }}`,
+			expectedError: "no allowed values declared for .Sort",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tmpl, err := gosq.Parse(c.template, append(c.opts, gosq.WithCompactWhitespace())...)
+			if !assert.NoError(t, err) {
+				return
+			}
+			variants, err := tmpl.Variants()
+			if c.expectedError != "" {
+				assert.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+
+			var actual []variant
+			for _, v := range variants {
+				actual = append(actual, variant{v.Predicates, v.Query})
+			}
+			assert.Equal(t, c.expected, actual)
+		})
+	}
+}
+
+func TestVariant_CompileArgs(t *testing.T) {
+	tmpl, err := gosq.Parse(`SELECT * FROM products
+		{{ [if] .InStock [then] WHERE stock > .MinStock [else] WHERE category = .Category }}`,
+		gosq.WithCompactWhitespace())
+	assert.NoError(t, err)
+	variants, err := tmpl.Variants()
+	assert.NoError(t, err)
+	if !assert.Len(t, variants, 2) {
+		return
+	}
+
+	args := map[string]interface{}{"InStock": false, "MinStock": 10, "Category": "food"}
+	q, bound, err := variants[1].CompileArgs(args)
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products WHERE stock > $1`, q)
+	assert.Equal(t, []interface{}{10}, bound)
+
+	q, err = variants[0].Compile(map[string]interface{}{"Category": "food"})
+	assert.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM products WHERE category = food`, q)
+
+	_, err = variants[0].Compile(map[string]interface{}{"InStock": true})
+	assert.ErrorContains(t, err, "no value given for variable .Category")
+}
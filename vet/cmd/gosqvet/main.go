@@ -0,0 +1,22 @@
+// Command gosqvet checks the calls of gosq.Compile, gosq.Execute and their
+// variants against their constant templates, with the analyzer of package
+// vet. It reports the variables referenced in a template which the args lack,
+// or which have the wrong type.
+//
+// Usage:
+//
+//	gosqvet [flags] [package ...]
+//
+// It can be run on its own, or by go vet:
+//
+//	go vet -vettool=$(which gosqvet) ./...
+package main
+
+import (
+	"github.com/sanggonlee/gosq/vet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(vet.Analyzer)
+}
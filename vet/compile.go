@@ -0,0 +1,187 @@
+package vet
+
+import (
+	goast "go/ast"
+	"go/constant"
+	"go/types"
+
+	"github.com/sanggonlee/gosq/ast"
+)
+
+// argsMapType is the type of the args which are a map.
+var argsMapType = types.NewMap(types.Typ[types.String], types.NewInterfaceType(nil, nil).Complete())
+
+// argField is a value of the args which a variable can reference.
+type argField struct {
+	typ types.Type
+	// desc describes the value in the reports, such as field Category of
+	// filter.
+	desc string
+}
+
+// checkCompile checks the call against a template of the Compile syntax, and
+// the options it's given.
+func (c *checker) checkCompile(tmpl string, opts []goast.Expr) {
+	st, err := parseTemplate(tmpl)
+	if err != nil {
+		c.reportf(c.call.Args[0], "invalid template: %v", err)
+		return
+	}
+	params, err := st.Params()
+	if err != nil {
+		c.reportf(c.call.Args[0], "invalid template: %v", err)
+		return
+	}
+	fields, missing, ok := c.compileArgs()
+	if !ok {
+		return
+	}
+	checkMissing := true
+	for _, opt := range opts {
+		if c.isGosqCall(opt, "WithMissingKey") {
+			checkMissing = false
+		}
+	}
+
+	declared := make(map[string]ast.Param)
+	for _, p := range params {
+		declared[p.Name] = p
+	}
+	keywords := stringBlocks(st)
+	// A variable is reported once, even if it's referenced many times.
+	reported := make(map[string]bool)
+	for _, v := range st.Variables() {
+		if reported[v.Name] {
+			continue
+		}
+		p, isParam := declared[v.Name]
+		f, ok := fields[fieldName(v.Name)]
+		if !ok {
+			if checkMissing && !v.HasDefault && !isParam {
+				c.reportf(c.args, missing, fieldName(v.Name), v.Name)
+				reported[v.Name] = true
+			}
+			continue
+		}
+		if isUnknown(f.typ) {
+			continue
+		}
+
+		reported[v.Name] = true
+		switch {
+		case isParam && !matchesParamType(p.Type, f.typ):
+			c.reportf(c.args, "parameter %s must be %s, but %s is %s", v.Name, p.Type, f.desc, c.typeString(f.typ))
+		case v.Predicate && !v.Piped && basicInfo(f.typ)&(types.IsBoolean|types.IsString) == 0:
+			c.reportf(c.args, "predicate %s must be a bool, but %s is %s", v.Name, f.desc, c.typeString(f.typ))
+		case keywords[v.Name] != "" && !types.Identical(f.typ, types.Typ[types.String]):
+			// The value is asserted to be a string, so a named string type
+			// doesn't do either.
+			c.reportf(c.args, "%s %s must be a string, but %s is %s", keywords[v.Name], v.Name, f.desc, c.typeString(f.typ))
+		default:
+			reported[v.Name] = false
+		}
+	}
+}
+
+// parseTemplate parses a template of the Compile syntax, and resolves the
+// partials it defines.
+func parseTemplate(tmpl string) (*ast.SyntaxTree, error) {
+	tt, err := ast.BuildTokenTree(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	node, err := tt.Parse()
+	if err != nil {
+		return nil, err
+	}
+	// Parsing a TokenTree always results in a SyntaxTree.
+	st := node.(*ast.SyntaxTree)
+
+	defines, err := st.Defines()
+	if err != nil {
+		return nil, err
+	}
+	if err = st.ResolveIncludes(defines); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// compileArgs returns the values of the args of a Compile call by name, along
+// with the format of the report of a missing one. ok is false if they can't
+// be known, in which case it's reported if need be.
+func (c *checker) compileArgs() (fields map[string]argField, missing string, ok bool) {
+	if c.isNil() {
+		// Nil args are the same as empty ones.
+		return nil, "no args are given, but %[2]s is referenced in the template", true
+	}
+	t := c.pass.TypesInfo.TypeOf(c.args)
+	if t == nil || types.IsInterface(t) {
+		return nil, "", false
+	}
+
+	switch u := t.Underlying().(type) {
+	case *types.Struct:
+		// The fields are looked up by reflection, so the embedded ones
+		// aren't promoted.
+		fields = make(map[string]argField)
+		name := c.typeString(t)
+		for i := 0; i < u.NumFields(); i++ {
+			f := u.Field(i)
+			fields[f.Name()] = argField{typ: f.Type(), desc: "field " + f.Name() + " of " + name}
+		}
+		return fields, name + " has no field %s, referenced as %s in the template", true
+	case *types.Map:
+		// The args are asserted to be a map[string]interface{}, so no other
+		// map type does, not even a named one.
+		if types.Identical(t, argsMapType) {
+			return c.mapLiteral()
+		}
+	}
+	c.reportf(c.args, "unsupported args type %s, pass a struct or a map[string]interface{}", c.typeString(t))
+	return nil, "", false
+}
+
+// mapLiteral returns the values of args which are a map literal by key. Other
+// maps are reported, since their keys can't be known.
+func (c *checker) mapLiteral() (map[string]argField, string, bool) {
+	lit, ok := goast.Unparen(c.args).(*goast.CompositeLit)
+	if !ok {
+		if !allowMaps {
+			c.reportf(c.args, "args of type %s can't be checked against the template, pass a struct instead",
+				c.typeString(c.pass.TypesInfo.TypeOf(c.args)))
+		}
+		return nil, "", false
+	}
+
+	fields := make(map[string]argField)
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*goast.KeyValueExpr)
+		if !ok {
+			return nil, "", false
+		}
+		key := c.pass.TypesInfo.Types[kv.Key].Value
+		if key == nil || key.Kind() != constant.String {
+			return nil, "", false
+		}
+		k := constant.StringVal(key)
+		fields[k] = argField{typ: c.pass.TypesInfo.TypeOf(kv.Value), desc: "key " + k}
+	}
+	return fields, "args have no key %s, referenced as %s in the template", true
+}
+
+// matchesParamType checks if values of the type can be given for a parameter
+// declared with the type, the same way as the parameter is resolved.
+func matchesParamType(param string, t types.Type) bool {
+	info := basicInfo(t)
+	switch param {
+	case "string":
+		return info&types.IsString != 0
+	case "bool":
+		return info&types.IsBoolean != 0
+	case "int", "float":
+		// Whole floats are accepted as ints.
+		return info&(types.IsInteger|types.IsFloat) != 0
+	}
+	return true
+}
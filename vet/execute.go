@@ -0,0 +1,149 @@
+package vet
+
+import (
+	"go/types"
+	"text/template/parse"
+)
+
+// checkExecute checks the call against a template of the text/template
+// syntax. The fields referenced on dot, or on $, are looked up in the type of
+// the args, as far as dot is known: it's not followed into {{range}} and
+// {{with}}, nor into variables.
+func (c *checker) checkExecute(tmpl string) {
+	tree := parse.New("gosq")
+	// The functions are only known at run time, from the options.
+	tree.Mode = parse.SkipFuncCheck
+	trees := make(map[string]*parse.Tree)
+	if _, err := tree.Parse(tmpl, "", "", trees); err != nil {
+		c.reportf(c.call.Args[0], "invalid template: %v", err)
+		return
+	}
+	if c.isNil() {
+		return
+	}
+	t := c.pass.TypesInfo.TypeOf(c.args)
+	if t == nil || types.IsInterface(t) {
+		return
+	}
+
+	e := &executeChecker{
+		checker:  c,
+		root:     t,
+		trees:    trees,
+		visited:  make(map[string]bool),
+		reported: make(map[string]bool),
+	}
+	if root, ok := trees["gosq"]; ok {
+		e.node(root.Root, t)
+	}
+}
+
+// executeChecker checks the nodes of a text/template.
+type executeChecker struct {
+	*checker
+	// root is the type of the args, which $ refers to.
+	root  types.Type
+	trees map[string]*parse.Tree
+	// visited are the names of the templates already checked with dot set to
+	// the args.
+	visited map[string]bool
+	// reported are the references already reported.
+	reported map[string]bool
+}
+
+// node checks the node, with dot of the type given, or nil if it's unknown.
+func (e *executeChecker) node(node parse.Node, dot types.Type) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			e.node(child, dot)
+		}
+	case *parse.ActionNode:
+		e.node(n.Pipe, dot)
+	case *parse.IfNode:
+		e.node(n.Pipe, dot)
+		e.node(n.List, dot)
+		e.node(n.ElseList, dot)
+	case *parse.RangeNode:
+		e.node(n.Pipe, dot)
+		e.node(n.List, nil)
+		e.node(n.ElseList, dot)
+	case *parse.WithNode:
+		e.node(n.Pipe, dot)
+		e.node(n.List, nil)
+		e.node(n.ElseList, dot)
+	case *parse.TemplateNode:
+		e.node(n.Pipe, dot)
+		if !isDot(n.Pipe) || dot != e.root || e.visited[n.Name] {
+			return
+		}
+		e.visited[n.Name] = true
+		if t, ok := e.trees[n.Name]; ok {
+			e.node(t.Root, dot)
+		}
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			e.node(cmd, dot)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			e.node(arg, dot)
+		}
+	case *parse.FieldNode:
+		e.fields(dot, n.Ident, n.String())
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			e.fields(e.root, n.Ident[1:], n.String())
+		}
+	}
+}
+
+// isDot checks if the pipeline is a single dot.
+func isDot(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+	_, ok := pipe.Cmds[0].Args[0].(*parse.DotNode)
+	return ok
+}
+
+// fields looks up the chain of fields, such as Product.Name for
+// .Product.Name, starting from the type given, and reports the first one
+// which isn't found.
+func (e *executeChecker) fields(t types.Type, names []string, ref string) {
+	for _, name := range names {
+		if t == nil || types.IsInterface(t) {
+			return
+		}
+		if _, isMap := deref(t).Underlying().(*types.Map); isMap {
+			// The keys are only known at run time.
+			return
+		}
+
+		obj, _, _ := types.LookupFieldOrMethod(t, true, nil, name)
+		if obj == nil || !obj.Exported() {
+			if !e.reported[ref] {
+				e.reported[ref] = true
+				e.reportf(e.args, "%s has no field or method %s, referenced as %s in the template",
+					e.typeString(t), name, ref)
+			}
+			return
+		}
+
+		switch obj := obj.(type) {
+		case *types.Var:
+			t = obj.Type()
+		case *types.Func:
+			t = nil
+			if results := obj.Type().(*types.Signature).Results(); results.Len() > 0 {
+				t = results.At(0).Type()
+			}
+		}
+	}
+}
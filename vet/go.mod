@@ -0,0 +1,16 @@
+module github.com/sanggonlee/gosq/vet
+
+// golang.org/x/tools needs go 1.22, and its versions which need less don't
+// build with recent Go releases.
+go 1.22.0
+
+require (
+	github.com/sanggonlee/gosq v0.1.0
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
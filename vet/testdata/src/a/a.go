@@ -0,0 +1,66 @@
+package a
+
+import (
+	"database/sql"
+
+	"github.com/sanggonlee/gosq"
+)
+
+type filter struct {
+	Category       string
+	IncludeReviews bool
+	Limit          int
+	Sort           string
+	Dir            direction
+	Active         sql.NullBool
+	Product        product
+	Extra          interface{}
+}
+
+type direction string
+
+type product struct {
+	Name string
+}
+
+func (p product) Label() string { return p.Name }
+
+type args map[string]interface{}
+
+const products = `SELECT * FROM products WHERE category = .Category`
+
+func compile(f filter, m map[string]interface{}, tmpl string) {
+	gosq.Compile(products, f)
+	gosq.Compile(`SELECT * FROM products {{ [if] .IncludeReviews [then] JOIN reviews }} LIMIT .Limit`, f)
+	gosq.Compile(`{{ [if] .Active [then] WHERE active }} {{ [if] .Extra [then] AND extra }}`, f)
+	gosq.Compile(`SELECT * FROM products WHERE on_sale = .OnSale`, f)               // want `filter has no field OnSale, referenced as .OnSale in the template`
+	gosq.Compile(`SELECT * FROM products {{ [if] .Limit [then] LIMIT 10 }}`, f)     // want `predicate .Limit must be a bool, but field Limit of filter is int`
+	gosq.Compile(`ORDER BY {{ [oneof] .Sort "name" "price" }} {{ [dir] .Dir }}`, f) // want `\[dir\] .Dir must be a string, but field Dir of filter is direction`
+	gosq.Compile(`{{ [params] .Limit:string }} LIMIT .Limit`, f)                    // want `parameter .Limit must be string, but field Limit of filter is int`
+	gosq.Compile(`{{ [params] .Page:int=1 }} {{ [if] .Flag [default] false [then] x }} OFFSET .Page`, f)
+	gosq.Compile(`WHERE x = .Missing`, f, gosq.WithMissingKey(gosq.MissingKeyZero))
+	gosq.Compile(`SELECT {{ [if] .A [then] }`, f) // want `invalid template: .*`
+	gosq.Compile(tmpl, f)
+	gosq.Compile(products, nil)                      // want `no args are given, but .Category is referenced in the template`
+	gosq.Compile(products, &f)                       // want `unsupported args type \*filter, pass a struct or a map\[string\]interface\{\}`
+	gosq.Compile(products, args{"Category": "food"}) // want `unsupported args type args, pass a struct or a map\[string\]interface\{\}`
+	gosq.Compile(products, m)                        // want `args of type map\[string\]interface\{\} can't be checked against the template, pass a struct instead`
+	gosq.Compile(products, map[string]interface{}{"Category": "food"})
+	gosq.Compile(products+` AND .InStock`, map[string]interface{}{"Category": 1})  // want `args have no key InStock, referenced as .InStock in the template`
+	gosq.CompileArgs(`{{ [ident] .Column }}`, map[string]interface{}{"Column": 1}) // want `\[ident\] .Column must be a string, but key Column is int`
+	gosq.New().Compile(`WHERE x = .X`, f)                                          // want `filter has no field X, referenced as .X in the template`
+}
+
+func execute(f filter, p *product) {
+	gosq.Execute(`SELECT * FROM products WHERE category = {{ bind .Category }} {{ if .IncludeReviews }}x{{ end }}`, f)
+	gosq.Execute(`{{ .Product.Name }} {{ .Product.Label }} {{ $.Limit }}`, &f)
+	gosq.Execute(`{{ range .Extra }}{{ .Anything }}{{ end }} {{ with .Product }}{{ .Name }}{{ end }}`, f)
+	gosq.Execute(`{{ .Name }}`, p)
+	gosq.Execute(`{{ .Product.Nme }}`, f)                                      // want `product has no field or method Nme, referenced as .Product.Nme in the template`
+	gosq.ExecuteArgs(`{{ bind .OnSale }}`, f)                                  // want `filter has no field or method OnSale, referenced as .OnSale in the template`
+	gosq.Execute(`{{ if .Category }}{{ end }} {{ $.Count }}`, f)               // want `filter has no field or method Count, referenced as \$.Count in the template`
+	gosq.Execute(`{{ define "x" }}{{ .Foo }}{{ end }}{{ template "x" . }}`, f) // want `filter has no field or method Foo, referenced as .Foo in the template`
+	gosq.Execute(`{{ .Category`, f)                                            // want `invalid template: .*`
+	gosq.New().Execute(`{{ .category }}`, f)                                   // want `filter has no field or method category, referenced as .category in the template`
+	gosq.Execute(`{{ .Anything }}`, map[string]interface{}{})
+}
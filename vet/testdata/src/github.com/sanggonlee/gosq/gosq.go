@@ -0,0 +1,30 @@
+// Package gosq is a stub of the functions checked by the analyzer.
+package gosq
+
+type Option func()
+
+type MissingKey int
+
+const MissingKeyZero MissingKey = 1
+
+func WithMissingKey(mk MissingKey) Option { return nil }
+
+func Compile(template string, args interface{}, opts ...Option) (string, error) { return "", nil }
+
+func CompileArgs(template string, args interface{}, opts ...Option) (string, []interface{}, error) {
+	return "", nil, nil
+}
+
+func Execute(str string, args interface{}, opts ...Option) (string, error) { return "", nil }
+
+func ExecuteArgs(str string, args interface{}, opts ...Option) (string, []interface{}, error) {
+	return "", nil, nil
+}
+
+type Compiler struct{}
+
+func New(opts ...Option) *Compiler { return &Compiler{} }
+
+func (c *Compiler) Compile(tmpl string, args interface{}) (string, error) { return "", nil }
+
+func (c *Compiler) Execute(str string, args interface{}) (string, error) { return "", nil }
@@ -0,0 +1,218 @@
+// Package vet provides an analyzer which checks the calls of gosq.Compile,
+// gosq.Execute and their variants against the templates they're given.
+//
+// When the template of a call is a constant, it's parsed, and the variables it
+// references are looked up in the type of the args:
+//
+//	type filter struct {
+//		Category string
+//		InStock  int
+//	}
+//
+//	gosq.Compile(`SELECT * FROM products
+//		WHERE category = .Category
+//		{{ [if] .InStock [then] AND stock > 0 }}
+//		{{ [if] .OnSale [then] AND on_sale }}`, filter{})
+//	// predicate .InStock must be a bool, but field InStock of filter is int
+//	// filter has no field OnSale, referenced as .OnSale in the template
+//
+// Args which are a map can't be checked, unless they're a map literal with
+// constant keys, so they're reported too, unless the -allowmaps flag is set.
+// The analyzer can be run with go vet through cmd/gosqvet:
+//
+//	go vet -vettool=$(which gosqvet) ./...
+package vet
+
+import (
+	goast "go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+
+	"github.com/sanggonlee/gosq/ast"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const gosqPath = "github.com/sanggonlee/gosq"
+
+// Analyzer checks the calls of gosq.Compile, gosq.Execute and their variants
+// against the templates they're given.
+var Analyzer = &analysis.Analyzer{
+	Name:     "gosq",
+	Doc:      "check the args of gosq.Compile and gosq.Execute calls against their templates",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var allowMaps bool
+
+func init() {
+	Analyzer.Flags.BoolVar(&allowMaps, "allowmaps", false, "don't report args which are maps, and can't be checked")
+}
+
+// syntax is the syntax of the templates of a function.
+type syntax int
+
+const (
+	syntaxCompile syntax = iota
+	syntaxExecute
+)
+
+// funcs are the syntaxes of the functions checked, by name. They're the same
+// for the functions of the package and the methods of Compiler.
+var funcs = map[string]syntax{
+	"Compile":           syntaxCompile,
+	"CompileArgs":       syntaxCompile,
+	"Execute":           syntaxExecute,
+	"ExecuteArgs":       syntaxExecute,
+	"ExecuteWithOption": syntaxExecute,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]goast.Node{(*goast.CallExpr)(nil)}, func(n goast.Node) {
+		call := n.(*goast.CallExpr)
+		s, ok := calledSyntax(pass, call)
+		if !ok || len(call.Args) < 2 {
+			return
+		}
+		tv := pass.TypesInfo.Types[call.Args[0]]
+		if tv.Value == nil || tv.Value.Kind() != constant.String {
+			return
+		}
+		tmpl := constant.StringVal(tv.Value)
+
+		c := &checker{pass: pass, call: call, args: call.Args[1]}
+		switch s {
+		case syntaxCompile:
+			c.checkCompile(tmpl, call.Args[2:])
+		case syntaxExecute:
+			c.checkExecute(tmpl)
+		}
+	})
+	return nil, nil
+}
+
+// calledSyntax returns the syntax of the templates of the gosq function or
+// Compiler method called, if it's one of the checked ones.
+func calledSyntax(pass *analysis.Pass, call *goast.CallExpr) (syntax, bool) {
+	fn, ok := typeutil.Callee(pass.TypesInfo, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != gosqPath {
+		return 0, false
+	}
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		named, ok := deref(recv.Type()).(*types.Named)
+		if !ok || named.Obj().Name() != "Compiler" {
+			return 0, false
+		}
+	}
+	s, ok := funcs[fn.Name()]
+	return s, ok
+}
+
+// checker checks a call against its template.
+type checker struct {
+	pass *analysis.Pass
+	call *goast.CallExpr
+	args goast.Expr
+}
+
+func (c *checker) reportf(node goast.Node, format string, args ...interface{}) {
+	c.pass.ReportRangef(node, format, args...)
+}
+
+// typeString returns the name of the type, qualified by its package unless
+// it's the package being checked.
+func (c *checker) typeString(t types.Type) string {
+	return types.TypeString(t, types.RelativeTo(c.pass.Pkg))
+}
+
+// isNil checks if the args are the untyped nil.
+func (c *checker) isNil() bool {
+	return c.pass.TypesInfo.Types[c.args].IsNil()
+}
+
+// isGosqCall checks if the expression is a call of the gosq function of the
+// given name.
+func (c *checker) isGosqCall(expr goast.Expr, name string) bool {
+	call, ok := goast.Unparen(expr).(*goast.CallExpr)
+	if !ok {
+		return false
+	}
+	fn, ok := typeutil.Callee(c.pass.TypesInfo, call).(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == gosqPath && fn.Name() == name
+}
+
+// deref returns the type pointed to by t, or t itself if it's not a pointer.
+func deref(t types.Type) types.Type {
+	if p, ok := t.Underlying().(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+// isValuer checks if the type implements driver.Valuer, which gosq replaces
+// with its value.
+func isValuer(t types.Type) bool {
+	obj, _, _ := types.LookupFieldOrMethod(t, true, nil, "Value")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	return sig.Params().Len() == 0 && sig.Results().Len() == 2
+}
+
+// isUnknown checks if the type of a value is only known at run time.
+func isUnknown(t types.Type) bool {
+	return t == nil || types.IsInterface(t) || isValuer(t)
+}
+
+// basicInfo returns the info of the underlying basic type of t, or 0 if it's
+// not a basic type.
+func basicInfo(t types.Type) types.BasicInfo {
+	if b, ok := t.Underlying().(*types.Basic); ok {
+		return b.Info()
+	}
+	return 0
+}
+
+// fieldName returns the name of the field referenced by a variable of the
+// template, such as Category for .Category.
+func fieldName(variable string) string {
+	return strings.TrimPrefix(variable, ".")
+}
+
+// stringBlocks returns the keywords of the blocks which need the values of
+// their variables to be strings, by the names of the variables.
+func stringBlocks(st *ast.SyntaxTree) map[string]string {
+	keywords := make(map[string]string)
+	ast.Walk(st, visitor(func(n ast.LanguageNode) {
+		switch n := n.(type) {
+		case *ast.IdentBlock:
+			keywords[n.Name] = "[ident]"
+		case *ast.AllowlistBlock:
+			keywords[n.Name] = n.Keyword
+		case *ast.IncludeBlock:
+			if n.Tree != nil {
+				for name, keyword := range stringBlocks(n.Tree) {
+					keywords[name] = keyword
+				}
+			}
+		}
+	}))
+	return keywords
+}
+
+type visitor func(ast.LanguageNode)
+
+func (f visitor) Visit(node ast.LanguageNode) ast.Visitor {
+	if node == nil {
+		return nil
+	}
+	f(node)
+	return f
+}